@@ -0,0 +1,652 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/huh/spinner"
+	"github.com/cli/go-gh/v2/pkg/term"
+	"github.com/spf13/cobra"
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+	"github.com/vladimir-ananiev/gh-stack/pkg/github"
+)
+
+type cascadeOptions struct {
+	Continue    bool
+	Abort       bool
+	Skip        bool
+	DryRun      bool
+	Force       bool
+	FromCurrent bool
+	Branch      string
+	All         bool
+	Autostash   bool
+	Worktree    bool
+	Atomic      bool
+	Yes         bool
+	NoCheck     bool
+}
+
+var cascadeOpts cascadeOptions
+
+var cascadeCmd = &cobra.Command{
+	Use:   "cascade",
+	Short: "Cascade rebase all branches in dependency order",
+	Long: `Checkout default branch (main/master), pull, then for each branch with PR targeting the default branch:
+	1. Checkout branch, rebase on target, push
+	2. For each dependent branch, checkout, rebase, push
+	
+Handles merged branches by dropping commits already in target.
+
+Progress is saved under .git/ so a cascade stopped by a conflict can be resumed
+with --continue, the conflicted branch left as is with --skip, or every branch
+restored to its pre-cascade commit with --abort.
+
+Use --dry-run to print the plan without changing any branch.
+
+By default the whole stack containing the current branch is cascaded. Use
+--from-current to restack only the current branch and its descendants, or
+--branch <name> to start from any branch of a stack. These rebase onto the
+parent branch as it is locally, without pulling it, and leave the parent and
+its other children untouched.
+
+Use --all to cascade every stack in the repository. A branch that conflicts,
+has diverged from its remote, fails to push or whose base can't be updated is
+then left as it was along with its descendants, and the cascade carries on with
+the other stacks, ending with a summary of each stack.
+
+A cascade refuses to start when a branch is behind or has diverged from its
+remote, for example because a teammate pushed to it, since force-pushing would
+drop their commits. Use --force to cascade anyway.
+
+A cascade also refuses to start with uncommitted changes, since they would
+block checking out other branches. Use --autostash to stash them first and
+restore them on the original branch once the cascade finishes or is aborted.
+
+Use --worktree to rebase in a temporary worktree under .git/ instead of checking
+out each branch in your checkout, which stays on the current branch. Conflicts
+are then resolved in that worktree. It is removed when the cascade finishes or
+is aborted.
+
+Use --atomic to rebase every branch locally first and push them all in a single
+atomic push, so a conflict halfway never leaves the remote half restacked. Each
+branch is leased on the SHA it had on origin when the cascade started; if any
+of them moved since, nothing is pushed.
+
+Before changing anything, every rebase is simulated and, if some branches are
+expected to conflict, you're asked whether to go ahead. With --all, --yes or
+without a terminal to ask on, the predictions are only printed. Use --no-check
+to skip the simulation. 'gh stack check' runs the same simulation on its own.
+
+The stack navigation section in each PR description is refreshed before rebasing.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cascadeRebase(cmd.Context(), github.NewClient(), git.NewRepo(""), cascadeOpts)
+	},
+}
+
+func init() {
+	cascadeCmd.Flags().BoolVar(&cascadeOpts.Continue, "continue", false, "Resume a cascade after resolving a conflict")
+	cascadeCmd.Flags().BoolVar(&cascadeOpts.Abort, "abort", false, "Abort a cascade and restore all branches")
+	cascadeCmd.Flags().BoolVar(&cascadeOpts.Skip, "skip", false, "Skip the conflicted branch and resume the cascade")
+	cascadeCmd.Flags().BoolVar(&cascadeOpts.DryRun, "dry-run", false, "Print the cascade plan without changing any branch")
+	cascadeCmd.Flags().BoolVar(&cascadeOpts.Force, "force", false, "Cascade even if branches have diverged from their remote")
+	cascadeCmd.MarkFlagsMutuallyExclusive("continue", "abort", "skip", "dry-run")
+	cascadeCmd.Flags().BoolVar(&cascadeOpts.FromCurrent, "from-current", false, "Only cascade the current branch and its descendants")
+	cascadeCmd.Flags().StringVar(&cascadeOpts.Branch, "branch", "", "Only cascade the given branch and its descendants")
+	cascadeCmd.MarkFlagsMutuallyExclusive("continue", "abort", "skip", "force")
+	cascadeCmd.Flags().BoolVar(&cascadeOpts.All, "all", false, "Cascade every stack in the repository")
+	cascadeCmd.Flags().BoolVar(&cascadeOpts.Autostash, "autostash", false, "Stash uncommitted changes before the cascade and restore them after")
+	cascadeCmd.MarkFlagsMutuallyExclusive("continue", "abort", "skip", "from-current", "branch", "all")
+	cascadeCmd.Flags().BoolVar(&cascadeOpts.Worktree, "worktree", false, "Rebase in a temporary worktree, leaving your checkout alone")
+	cascadeCmd.MarkFlagsMutuallyExclusive("continue", "abort", "skip", "autostash")
+	cascadeCmd.MarkFlagsMutuallyExclusive("continue", "abort", "skip", "worktree")
+	cascadeCmd.Flags().BoolVar(&cascadeOpts.Atomic, "atomic", false, "Push all branches in one atomic push once every rebase is done")
+	cascadeCmd.MarkFlagsMutuallyExclusive("continue", "abort", "skip", "atomic")
+	cascadeCmd.Flags().BoolVarP(&cascadeOpts.Yes, "yes", "y", false, "Cascade without asking when conflicts are predicted")
+	cascadeCmd.Flags().BoolVar(&cascadeOpts.NoCheck, "no-check", false, "Don't simulate the rebases to predict conflicts first")
+	cascadeCmd.MarkFlagsMutuallyExclusive("continue", "abort", "skip", "yes", "no-check")
+
+	rootCmd.AddCommand(cascadeCmd)
+}
+
+func cascadeRebase(ctx context.Context, client github.Client, repo git.Git, opts cascadeOptions) error {
+	state, err := github.LoadCascadeState(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	if opts.Continue || opts.Abort || opts.Skip {
+		if state == nil {
+			fmt.Printf("%s no cascade in progress\n", errorStyle.Render("✗ Error:"))
+			return nil
+		}
+		return resumeCascade(ctx, repo, state, opts)
+	}
+
+	if state != nil {
+		fmt.Printf("%s a cascade is already in progress\n\n", errorStyle.Render("✗ Error:"))
+		fmt.Printf("%s Use 'gh stack cascade --continue', '--skip' or '--abort'\n",
+			hintStyle.Render("Hint:"))
+		return nil
+	}
+
+	// Get current branch to restore later
+	currentBranch, err := repo.GetCurrentBranch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	// Get all open PRs and build dependency tree
+	prs, err := fetchOpenPRs(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	// Branches whose PR was retargeted after a parent was merged must skip the parent's commits
+	merged, err := fetchClosedPRs(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	tree, err := buildStackTree(ctx, repo, prs, merged)
+	if err != nil {
+		return err
+	}
+
+	// Pick the trees to cascade and where in them to start
+	stacks, starts := tree, tree
+	pullBase := true
+	if !opts.All {
+		from := currentBranch
+		if opts.Branch != "" {
+			from = opts.Branch
+		}
+		currentTree := github.FindCurrentBranchTree(tree, from)
+		if currentTree == nil {
+			fmt.Printf("%s %s has no open PR or is not part of a stack\n\n",
+				errorStyle.Render("✗ Error:"),
+				warningStyle.Render(from))
+			if opts.Branch != "" {
+				fmt.Printf("%s Run 'gh stack' to see the branches of your stacks\n", hintStyle.Render("Hint:"))
+			} else {
+				fmt.Printf("%s Switch to a branch that has an open PR to use cascade, or use --all\n",
+					hintStyle.Render("Hint:"))
+			}
+			return nil // Return nil to prevent cobra from showing the error again
+		}
+
+		// Only restack the subtree below the starting branch if asked to
+		stacks, starts = []*github.TreeNode{currentTree}, []*github.TreeNode{currentTree}
+		if opts.FromCurrent || opts.Branch != "" {
+			starts = []*github.TreeNode{github.FindBranchNode(tree, from)}
+		}
+
+		// Only a stack's own base is pulled, a parent branch is taken as it is locally
+		pullBase = starts[0] == currentTree
+	}
+
+	if len(starts) == 0 {
+		fmt.Println("No stacks to cascade")
+		return nil
+	}
+
+	// Force-pushing a branch someone else pushed to would silently drop their commits
+	if err := github.AnnotateDivergence(ctx, repo, starts); err != nil {
+		return err
+	}
+	diverged := github.FindDiverged(starts)
+
+	// Uncommitted changes would block checking out the other branches. A worktree leaves the checkout
+	// alone, unless its branch is rebased or pulled, which resets it and could clobber the changes.
+	var modified []string
+	if !opts.Worktree || touchesCheckout(starts, currentBranch, pullBase) {
+		if modified, err = repo.GetModifiedFiles(ctx); err != nil {
+			return err
+		}
+	}
+
+	if opts.DryRun {
+		printDivergence(diverged, warningStyle.Render("⚠ Warning:"))
+		if !opts.Autostash {
+			printModifiedFiles(modified, warningStyle.Render("⚠ Warning:"))
+		}
+		return printCascadePlan(ctx, repo, starts, currentBranch, pullBase, opts, merged)
+	}
+
+	// With --all, only the diverged branches and their descendants are held back
+	failed := make(map[string]string)
+	if len(diverged) > 0 && !opts.Force {
+		if !opts.All {
+			printDivergence(diverged, errorStyle.Render("✗ Error:"))
+			fmt.Printf("%s Pull the remote changes into these branches first, or use --force to overwrite them\n",
+				hintStyle.Render("Hint:"))
+			return nil
+		}
+
+		printDivergence(diverged, warningStyle.Render("⚠ Warning:"))
+		for _, pr := range diverged {
+			failed[pr.HeadRefName] = github.DescribeDivergence(pr.Divergence)
+		}
+	}
+
+	if len(modified) > 0 && !opts.Autostash {
+		printModifiedFiles(modified, errorStyle.Render("✗ Error:"))
+		fmt.Printf("%s Commit or stash them first, or use --autostash\n", hintStyle.Render("Hint:"))
+		return nil
+	}
+
+	// Warn about conflicts before touching anything, so the cascade can be put off
+	if !opts.NoCheck {
+		predictions, err := predictConflicts(ctx, repo, starts, currentBranch, pullBase, merged)
+		if errors.Is(err, git.ErrMergeTreeUnsupported) {
+			printNoPrediction(err)
+		} else if err != nil {
+			return err
+		}
+		if github.HasConflicts(predictions) {
+			fmt.Printf("%s some branches are expected to conflict:\n", warningStyle.Render("⚠ Warning:"))
+			github.PrintConflictPredictions(predictions)
+			fmt.Println()
+
+			// --all fails the conflicting stacks and carries on anyway
+			if !opts.Yes && !opts.All {
+				proceed, err := confirmConflicts()
+				if err != nil || !proceed {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, stack := range stacks {
+		if err := updateStackNavigation(ctx, client, stack); err != nil {
+			return err
+		}
+	}
+
+	var stashed string
+	if len(modified) > 0 {
+		if stashed, err = repo.Stash(ctx, "gh stack cascade --autostash"); err != nil {
+			return err
+		}
+		fmt.Printf("%s %d changed files\n", completedStyle.Render("✓ Stashed"), len(modified))
+	}
+
+	state, err = startCascade(ctx, repo, currentBranch, starts, pullBase, opts, stashed, failed, merged)
+	if err != nil {
+		// Nothing was rebased yet, so the changes go back where they came from
+		if stashed != "" {
+			if checkoutErr := repo.CheckoutBranch(ctx, currentBranch); checkoutErr != nil {
+				return errors.Join(err, checkoutErr)
+			}
+			popAutostash(ctx, repo, stashed)
+		}
+		return err
+	}
+
+	return runCascade(ctx, repo, state)
+}
+
+// startCascade pulls the bases of the trees if pullBase is set, then plans the cascade and saves it.
+// The branches in failed, and with --all those whose base couldn't be updated, are failed up front with their reason.
+func startCascade(ctx context.Context, repo git.Git, currentBranch string, roots []*github.TreeNode, pullBase bool, opts cascadeOptions, stashed string, failed map[string]string, merged []*github.PR) (*github.CascadeState, error) {
+	failedBases := make(map[string]string)
+
+	// Checkout each base branch and pull, or update it in place to leave the checkout alone
+	if pullBase {
+		for _, baseBranch := range cascadeBases(roots) {
+			err := spinner.New().
+				Title(fmt.Sprintf("Updating %s...", baseBranch)).
+				ActionWithErr(func(context.Context) error {
+					if opts.Worktree && baseBranch != currentBranch {
+						return repo.FastForwardBranch(ctx, baseBranch)
+					}
+					return repo.CheckoutAndPull(ctx, baseBranch)
+				}).
+				Run()
+			if err != nil && !opts.All {
+				return nil, fmt.Errorf("failed to update %s: %w", baseBranch, err)
+			}
+			if err != nil {
+				// Only the stacks on this base are held back
+				fmt.Printf("%s failed to update %s, skipping its stacks: %v\n",
+					errorStyle.Render("✗ Error:"), baseBranch, err)
+				failedBases[baseBranch] = fmt.Sprintf("failed to update %s", baseBranch)
+			}
+		}
+	}
+
+	// Record the plan before touching any branch so it can be resumed or aborted
+	state, err := planCascade(ctx, repo, currentBranch, roots, merged)
+	if err != nil {
+		return nil, err
+	}
+	state.All = opts.All
+	state.Autostash = stashed
+	state.Atomic = opts.Atomic
+	if opts.Worktree {
+		if err := github.CreateCascadeWorktree(ctx, repo, state); err != nil {
+			return nil, err
+		}
+	}
+	if err := state.Save(ctx, repo); err != nil {
+		return nil, err
+	}
+
+	for _, step := range state.Steps {
+		reason, ok := failed[step.Branch]
+		if !ok && state.Step(step.Base) == nil {
+			reason, ok = failedBases[step.Base]
+		}
+		// A descendant of a failed branch is already skipped
+		if !ok || step.Status != github.StepPending {
+			continue
+		}
+		if err := github.FailCascadeStep(ctx, repo, state, step, reason); err != nil {
+			return nil, err
+		}
+	}
+
+	return state, nil
+}
+
+func printCascadePlan(ctx context.Context, repo git.Git, roots []*github.TreeNode, currentBranch string, pullBase bool, opts cascadeOptions, merged []*github.PR) error {
+	state, err := planCascade(ctx, repo, currentBranch, roots, merged)
+	if err != nil {
+		return err
+	}
+
+	previews, err := github.PreviewCascade(ctx, repo, state, pullBase)
+	if err != nil {
+		return fmt.Errorf("failed to preview cascade: %w", err)
+	}
+
+	var pulled []string
+	if pullBase {
+		pulled = cascadeBases(roots)
+	}
+	github.PrintCascadePlan(previews, pulled, currentBranch, opts.Atomic)
+	if opts.NoCheck {
+		return nil
+	}
+
+	predictions, err := github.PredictConflicts(ctx, repo, state, pullBase)
+	if errors.Is(err, git.ErrMergeTreeUnsupported) {
+		fmt.Println()
+		printNoPrediction(err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if github.HasConflicts(predictions) {
+		fmt.Println()
+		fmt.Printf("%s some branches are expected to conflict:\n", warningStyle.Render("⚠ Warning:"))
+		github.PrintConflictPredictions(predictions)
+	}
+	return nil
+}
+
+// planCascade plans a cascade over the trees, replaying only the branches' own commits
+// for those whose PR was retargeted after a merged parent
+func planCascade(ctx context.Context, repo git.Git, currentBranch string, roots []*github.TreeNode, merged []*github.PR) (*github.CascadeState, error) {
+	state, err := github.NewCascadeState(ctx, repo, currentBranch, roots...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan cascade: %w", err)
+	}
+	if err := state.UseMergedParents(ctx, repo, merged); err != nil {
+		return nil, fmt.Errorf("failed to plan cascade: %w", err)
+	}
+	return state, nil
+}
+
+// predictConflicts plans a cascade over the trees and simulates its rebases. If pullBase is set,
+// origin is fetched first, so the bases are simulated as the cascade will pull them.
+func predictConflicts(ctx context.Context, repo git.Git, roots []*github.TreeNode, currentBranch string, pullBase bool, merged []*github.PR) ([]*github.ConflictPrediction, error) {
+	var predictions []*github.ConflictPrediction
+	err := spinner.New().
+		Title("Checking for conflicts...").
+		ActionWithErr(func(context.Context) error {
+			if pullBase {
+				if err := repo.Fetch(ctx); err != nil {
+					return err
+				}
+			}
+
+			state, err := planCascade(ctx, repo, currentBranch, roots, merged)
+			if err != nil {
+				return err
+			}
+			predictions, err = github.PredictConflicts(ctx, repo, state, pullBase)
+			return err
+		}).
+		Run()
+	return predictions, err
+}
+
+// printNoPrediction warns that the installed git can't predict conflicts, so the cascade goes ahead without
+func printNoPrediction(err error) {
+	fmt.Printf("%s %v, skipping the conflict check\n", warningStyle.Render("⚠ Warning:"), err)
+	fmt.Printf("%s Upgrade git, or use --no-check to skip it without this warning\n\n", hintStyle.Render("Hint:"))
+}
+
+// confirmConflicts asks whether to cascade despite predicted conflicts, going ahead when there is no terminal; replaced in tests
+var confirmConflicts = func() (bool, error) {
+	// Scripts and CI have no one to ask
+	if !term.IsTerminal(os.Stdin) {
+		return true, nil
+	}
+
+	var confirmed bool
+	err := huh.NewConfirm().
+		Title("Cascade anyway?").
+		Value(&confirmed).
+		Run()
+	return confirmed, err
+}
+
+// touchesCheckout reports whether cascading roots moves the checked out branch, by rebasing it or
+// by pulling it as the base of a stack
+func touchesCheckout(roots []*github.TreeNode, currentBranch string, pullBase bool) bool {
+	if github.FindBranchNode(roots, currentBranch) != nil {
+		return true
+	}
+	return pullBase && slices.Contains(cascadeBases(roots), currentBranch)
+}
+
+// cascadeBases returns the distinct base branches of the trees, in order
+func cascadeBases(roots []*github.TreeNode) []string {
+	var bases []string
+	for _, root := range roots {
+		if !slices.Contains(bases, root.PR.BaseRefName) {
+			bases = append(bases, root.PR.BaseRefName)
+		}
+	}
+	return bases
+}
+
+func resumeCascade(ctx context.Context, repo git.Git, state *github.CascadeState, opts cascadeOptions) error {
+	var err error
+
+	if opts.Abort {
+		err = spinner.New().
+			Title("Aborting cascade...").
+			ActionWithErr(func(context.Context) error {
+				return github.AbortCascade(ctx, repo, state)
+			}).
+			Run()
+		if err != nil {
+			return fmt.Errorf("failed to abort cascade: %w", err)
+		}
+		fmt.Printf("%s all branches restored\n", completedStyle.Render("✓ Aborted"))
+		if state.Autostash != "" {
+			popAutostash(ctx, repo, state.Autostash)
+		}
+		return nil
+	}
+
+	if opts.Skip {
+		if err := github.SkipCascadeStep(ctx, repo, state); err != nil {
+			return err
+		}
+	}
+
+	if err := runCascade(ctx, repo, state); err != nil {
+		return err
+	}
+
+	// The merged branches a sync or merge stopped short of offering are offered once it completes
+	if len(state.Merged) == 0 {
+		return nil
+	}
+	if state, err := github.LoadCascadeState(ctx, repo); err != nil || state != nil {
+		return err
+	}
+	return deleteMergedBranches(ctx, repo, state.Merged, state.OriginalBranch)
+}
+
+// runCascade processes every remaining step of the cascade and returns to the original branch
+func runCascade(ctx context.Context, repo git.Git, state *github.CascadeState) error {
+	var err error
+
+	for step := state.NextStep(); step != nil; step = state.NextStep() {
+		err = spinner.New().
+			Title(fmt.Sprintf("Rebasing %s → %s...", step.Branch, step.Base)).
+			ActionWithErr(func(context.Context) error {
+				return github.RunCascadeStep(ctx, repo, state, step)
+			}).
+			Run()
+		if errors.Is(err, git.ErrRebaseConflict) && state.All {
+			// Leave the branch as it was and carry on with the other stacks
+			if err := github.FailCascadeStep(ctx, repo, state, step, "conflicts with "+step.Base); err != nil {
+				return err
+			}
+			fmt.Printf("%s %s could not be rebased onto %s, skipping its descendants\n",
+				errorStyle.Render("✗ Conflict:"),
+				warningStyle.Render(step.Branch),
+				step.Base)
+			continue
+		}
+		if errors.Is(err, git.ErrRebaseConflict) {
+			fmt.Printf("%s %s could not be rebased onto %s\n\n",
+				errorStyle.Render("✗ Conflict:"),
+				warningStyle.Render(step.Branch),
+				step.Base)
+			if state.Worktree != "" {
+				fmt.Printf("%s Resolve the conflicts in %s, then run 'gh stack cascade --continue'\n",
+					hintStyle.Render("Hint:"), state.Worktree)
+			} else {
+				fmt.Printf("%s Resolve the conflicts, then run 'gh stack cascade --continue'\n",
+					hintStyle.Render("Hint:"))
+			}
+			fmt.Printf("%s Or use '--skip' to leave %s as is, or '--abort' to restore all branches\n",
+				hintStyle.Render("Hint:"), step.Branch)
+			return nil // Return nil to prevent cobra from showing the error again
+		}
+		if err != nil && state.All {
+			// A branch that can't be checked out or pushed only holds back its own stack
+			if err := github.FailCascadeStep(ctx, repo, state, step, err.Error()); err != nil {
+				return err
+			}
+			fmt.Printf("%s %s: %v, skipping its descendants\n",
+				errorStyle.Render("✗ Failed:"),
+				warningStyle.Render(step.Branch),
+				err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Restore original branch, which a cascade in a worktree never left
+	if state.Worktree == "" {
+		err = spinner.New().
+			Title(fmt.Sprintf("Returning to %s...", state.OriginalBranch)).
+			ActionWithErr(func(context.Context) error {
+				return repo.CheckoutBranch(ctx, state.OriginalBranch)
+			}).
+			Run()
+		if err != nil {
+			return fmt.Errorf("failed to restore branch %s: %w", state.OriginalBranch, err)
+		}
+	}
+
+	if state.Atomic {
+		err = spinner.New().
+			Title("Pushing rebased branches...").
+			ActionWithErr(func(context.Context) error {
+				return github.PushCascade(ctx, repo, state)
+			}).
+			Run()
+		if err != nil {
+			// Every branch is rebased, keep the cascade so the push can be retried or the rebases undone
+			fmt.Printf("%s %v\n\n", errorStyle.Render("✗ Error:"), err)
+			fmt.Printf("%s Nothing was pushed. A branch may have moved on origin since the cascade started\n",
+				hintStyle.Render("Hint:"))
+			fmt.Printf("%s Run 'gh stack cascade --continue' to retry the push, or '--abort' to restore all branches\n",
+				hintStyle.Render("Hint:"))
+			return nil
+		}
+		fmt.Printf("%s rebased branches\n", completedStyle.Render("✓ Pushed"))
+	}
+
+	if state.All {
+		github.PrintCascadeSummary(state)
+		if slices.ContainsFunc(state.Steps, func(step *github.CascadeStep) bool { return step.Status == github.StepFailed }) {
+			fmt.Printf("\n%s Once the problem is fixed, run 'gh stack cascade --branch <name>' on a failed branch\n",
+				hintStyle.Render("Hint:"))
+		}
+	}
+
+	if err := github.ClearCascadeState(ctx, repo); err != nil {
+		return err
+	}
+
+	if state.Autostash != "" {
+		popAutostash(ctx, repo, state.Autostash)
+	}
+	return nil
+}
+
+// popAutostash restores the changes stashed by --autostash in the stash commit sha onto the current branch,
+// leaving them in the stash with a hint if they conflict
+func popAutostash(ctx context.Context, repo git.Git, sha string) {
+	if err := repo.StashPop(ctx, sha); err != nil {
+		fmt.Printf("%s your uncommitted changes could not be restored cleanly\n\n", errorStyle.Render("✗ Error:"))
+		fmt.Printf("%s Resolve the conflicts, then drop the \"gh stack cascade --autostash\" entry of 'git stash list'\n",
+			hintStyle.Render("Hint:"))
+		return
+	}
+	fmt.Printf("%s uncommitted changes\n", completedStyle.Render("✓ Restored"))
+}
+
+// printDivergence lists the branches that differ from their remote under a heading
+func printDivergence(diverged []*github.PR, heading string) {
+	if len(diverged) == 0 {
+		return
+	}
+
+	fmt.Printf("%s these branches differ from their remote and would be overwritten:\n", heading)
+	for _, pr := range diverged {
+		fmt.Printf("  %s %s\n", warningStyle.Render(pr.HeadRefName), github.DescribeDivergence(pr.Divergence))
+	}
+	fmt.Println()
+}
+
+// printModifiedFiles lists the files with uncommitted changes under a heading
+func printModifiedFiles(files []string, heading string) {
+	if len(files) == 0 {
+		return
+	}
+
+	fmt.Printf("%s these files have uncommitted changes:\n", heading)
+	for _, file := range files {
+		fmt.Printf("  %s\n", warningStyle.Render(file))
+	}
+	fmt.Println()
+}
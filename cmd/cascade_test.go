@@ -0,0 +1,911 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+	"github.com/vladimir-ananiev/gh-stack/pkg/github"
+)
+
+func TestCascadeRebase(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	mainTip := gitOutput(t, "rev-parse", "main")
+	for _, branch := range []string{"feature-1", "feature-2", "origin/feature-1", "origin/feature-2"} {
+		if !isAncestor(t, mainTip, branch) {
+			t.Errorf("%s was not rebased onto main", branch)
+		}
+	}
+	if !isAncestor(t, "feature-1", "feature-2") {
+		t.Errorf("feature-2 was not rebased onto feature-1")
+	}
+
+	if current := gitOutput(t, "branch", "--show-current"); current != "feature-2" {
+		t.Errorf("current branch = %s, want feature-2", current)
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Errorf("cascade state was not cleared")
+	}
+}
+
+func TestCascadeRebaseRecordsParentTip(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	gitOutput(t, "config", "branch.feature-2.stackParent", "feature-1")
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	if tip, want := gitOutput(t, "config", "branch.feature-2.stackParentTip"), gitOutput(t, "rev-parse", "feature-1"); tip != want {
+		t.Errorf("recorded parent tip = %s, want %s", tip, want)
+	}
+}
+
+func TestCascadeRebaseAfterParentSquashMerged(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+
+	// With two commits, the squash of feature-1 doesn't match either one, so replaying them would conflict
+	gitOutput(t, "checkout", "feature-1")
+	commitFile(t, "shared.txt", "feature-1 v2\n", "Feature 1 follow-up")
+	gitOutput(t, "push")
+	gitOutput(t, "checkout", "feature-2")
+	gitOutput(t, "rebase", "feature-1")
+	gitOutput(t, "push", "--force")
+	feature1 := squashMergeOnRemote(t, "feature-1")
+
+	// #2 was retargeted to main on GitHub, without sync recording where feature-2 forked
+	prs := stackPRs()[1:]
+	prs[0].BaseRefName = "main"
+	merged := &github.PR{Number: 1, HeadRefName: "feature-1", BaseRefName: "main", HeadRefOid: feature1, State: "MERGED"}
+	client := &github.FakeClient{Open: prs, Closed: []*github.PR{merged}}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Fatalf("cascade stopped at a conflict replaying feature-1's commits")
+	}
+	if !isAncestor(t, "main", "origin/feature-2") {
+		t.Errorf("feature-2 was not rebased onto main")
+	}
+	if log := gitOutput(t, "log", "--format=%s", "main..feature-2"); log != "Feature 2" {
+		t.Errorf("feature-2 has %q on top of main, want only Feature 2", log)
+	}
+}
+
+func TestCascadeRebaseAfterRecordedParentMerged(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	gitOutput(t, "config", "branch.feature-2.stackParent", "feature-1")
+	gitOutput(t, "config", "branch.feature-2.stackParentTip", gitOutput(t, "rev-parse", "feature-1"))
+
+	// feature-1 is squash-merged and deleted, and GitHub retargets #2 to main
+	feature1 := squashMergeOnRemote(t, "feature-1")
+	prs := stackPRs()[1:]
+	prs[0].BaseRefName = "main"
+	merged := &github.PR{Number: 1, HeadRefName: "feature-1", BaseRefName: "main", HeadRefOid: feature1, State: "MERGED"}
+	client := &github.FakeClient{Open: prs, Closed: []*github.PR{merged}}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Fatalf("cascade stopped at a conflict")
+	}
+	if parent := gitOutput(t, "config", "branch.feature-2.stackParent"); parent != "main" {
+		t.Errorf("feature-2 stack parent = %s, want main", parent)
+	}
+	if log := gitOutput(t, "log", "--format=%s", "main..origin/feature-2"); log != "Feature 2" {
+		t.Errorf("feature-2 has %q on top of main, want only Feature 2", log)
+	}
+}
+
+func TestCascadeRebaseStopsOnDivergence(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+
+	// A teammate pushes to feature-1, which we haven't fetched
+	other := filepath.Join(filepath.Dir(mustGetwd(t)), "other")
+	runGit(t, other, "fetch", "origin")
+	runGit(t, other, "checkout", "feature-1")
+	writeFileIn(t, other, "teammate.txt", "teammate\n")
+	runGit(t, other, "add", ".")
+	runGit(t, other, "commit", "-m", "Teammate change")
+	runGit(t, other, "push", "origin", "feature-1")
+	theirs := runGit(t, other, "rev-parse", "HEAD")
+
+	before := gitOutput(t, "rev-parse", "feature-1", "feature-2")
+
+	prs := stackPRs()
+	prs[0].HeadRefOid = theirs
+	client := &github.FakeClient{Open: prs}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	if after := gitOutput(t, "rev-parse", "feature-1", "feature-2"); after != before {
+		t.Errorf("cascade changed branches despite divergence")
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Errorf("cascade started despite divergence")
+	}
+
+	// --force overwrites the teammate's push
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Force: true}); err != nil {
+		t.Fatalf("cascadeRebase(--force) error = %v", err)
+	}
+	if !isAncestor(t, "main", "origin/feature-2") {
+		t.Errorf("feature-2 was not rebased with --force")
+	}
+}
+
+func TestCascadeRebaseDryRun(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+
+	before := gitOutput(t, "rev-parse", "feature-1", "feature-2")
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{DryRun: true}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	if after := gitOutput(t, "rev-parse", "feature-1", "feature-2"); after != before {
+		t.Errorf("dry run changed branches")
+	}
+}
+
+func TestCascadeRebaseFromCurrent(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+
+	// feature-1 gets a new commit that feature-2 is missing
+	gitOutput(t, "checkout", "feature-1")
+	commitFile(t, "feature-1.txt", "more\n", "More feature 1")
+	gitOutput(t, "checkout", "feature-2")
+	before := gitOutput(t, "rev-parse", "main", "feature-1", "origin/feature-1")
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{FromCurrent: true}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	if after := gitOutput(t, "rev-parse", "main", "feature-1", "origin/feature-1"); after != before {
+		t.Errorf("cascade --from-current changed branches above feature-2")
+	}
+	if parent, want := gitOutput(t, "rev-parse", "origin/feature-2^"), gitOutput(t, "rev-parse", "feature-1"); parent != want {
+		t.Errorf("feature-2 was rebased onto %s, want feature-1 at %s", parent, want)
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Errorf("cascade state was not cleared")
+	}
+}
+
+func TestCascadeRebaseBranch(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+
+	// feature-3 is a sibling of feature-2, and feature-1 moves on
+	gitOutput(t, "checkout", "-b", "feature-3", "feature-1")
+	commitFile(t, "feature-3.txt", "feature-3\n", "Feature 3")
+	gitOutput(t, "push", "-u", "origin", "feature-3")
+	gitOutput(t, "checkout", "feature-1")
+	commitFile(t, "feature-1.txt", "more\n", "More feature 1")
+	gitOutput(t, "checkout", "feature-2")
+	before := gitOutput(t, "rev-parse", "feature-1", "feature-2")
+
+	prs := append(stackPRs(), &github.PR{Number: 3, Title: "Third", HeadRefName: "feature-3", BaseRefName: "feature-1"})
+	client := &github.FakeClient{Open: prs}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Branch: "feature-3"}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	if after := gitOutput(t, "rev-parse", "feature-1", "feature-2"); after != before {
+		t.Errorf("cascade --branch feature-3 changed other branches")
+	}
+	if !isAncestor(t, "feature-1", "origin/feature-3") {
+		t.Errorf("feature-3 was not rebased onto feature-1")
+	}
+	if current := gitOutput(t, "branch", "--show-current"); current != "feature-2" {
+		t.Errorf("current branch = %s, want feature-2", current)
+	}
+}
+
+func TestCascadeRebaseAll(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	pushConflictingMain(t)
+
+	// A second, independent stack on main
+	gitOutput(t, "checkout", "-b", "other-1", "main")
+	commitFile(t, "other.txt", "other\n", "Other 1")
+	gitOutput(t, "push", "-u", "origin", "other-1")
+	gitOutput(t, "checkout", "feature-2")
+	before := gitOutput(t, "rev-parse", "feature-1", "feature-2")
+
+	prs := append(stackPRs(), &github.PR{Number: 3, Title: "Other", HeadRefName: "other-1", BaseRefName: "main"})
+	client := &github.FakeClient{Open: prs}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{All: true}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	// feature-1 conflicts, so it and feature-2 are left alone while other-1 is rebased
+	if after := gitOutput(t, "rev-parse", "feature-1", "feature-2"); after != before {
+		t.Errorf("conflicted stack was changed")
+	}
+	if !isAncestor(t, "main", "origin/other-1") {
+		t.Errorf("other-1 was not rebased onto main")
+	}
+	if status := gitOutput(t, "status", "--porcelain"); status != "" {
+		t.Errorf("working tree left dirty:\n%s", status)
+	}
+	if current := gitOutput(t, "branch", "--show-current"); current != "feature-2" {
+		t.Errorf("current branch = %s, want feature-2", current)
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Errorf("cascade state was not cleared")
+	}
+}
+
+func TestCascadeRebaseAllCarriesOn(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	dir := filepath.Dir(mustGetwd(t))
+
+	// other-1 is behind origin, after a teammate pushed to it
+	gitOutput(t, "checkout", "-b", "other-1", "main")
+	commitFile(t, "other.txt", "other\n", "Other 1")
+	gitOutput(t, "push", "-u", "origin", "other-1")
+	runGit(t, filepath.Join(dir, "other"), "fetch", "origin")
+	runGit(t, filepath.Join(dir, "other"), "checkout", "-b", "other-1", "origin/other-1")
+	writeFileIn(t, filepath.Join(dir, "other"), "teammate.txt", "teammate\n")
+	runGit(t, filepath.Join(dir, "other"), "add", ".")
+	runGit(t, filepath.Join(dir, "other"), "commit", "-m", "Teammate change")
+	runGit(t, filepath.Join(dir, "other"), "push", "origin", "other-1")
+	gitOutput(t, "fetch", "origin")
+
+	// release-1 is based on a branch that can't be pulled
+	gitOutput(t, "checkout", "-b", "release", "main")
+	gitOutput(t, "checkout", "-b", "release-1")
+	commitFile(t, "release.txt", "release\n", "Release 1")
+
+	// Pushes to feature-2 are rejected
+	hook := filepath.Join(dir, "remote.git", "hooks", "pre-receive")
+	script := "#!/bin/sh\nwhile read old new ref; do [ \"$ref\" = refs/heads/feature-2 ] && exit 1; done\nexit 0\n"
+	if err := os.WriteFile(hook, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	gitOutput(t, "checkout", "feature-2")
+	before := gitOutput(t, "rev-parse", "feature-2", "other-1", "release-1")
+
+	prs := append(stackPRs(),
+		&github.PR{Number: 3, Title: "Other", HeadRefName: "other-1", BaseRefName: "main"},
+		&github.PR{Number: 4, Title: "Release", HeadRefName: "release-1", BaseRefName: "release"})
+	client := &github.FakeClient{Open: prs}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{All: true}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	if !isAncestor(t, "main", "origin/feature-1") {
+		t.Errorf("feature-1 was not rebased onto main")
+	}
+	if after := gitOutput(t, "rev-parse", "feature-2", "other-1", "release-1"); after != before {
+		t.Errorf("branches that failed were changed")
+	}
+	if current := gitOutput(t, "branch", "--show-current"); current != "feature-2" {
+		t.Errorf("current branch = %s, want feature-2", current)
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Errorf("cascade state was not cleared")
+	}
+}
+
+func TestCascadeRebaseUncommittedChanges(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	writeFile(t, "feature-2.txt", "work in progress\n")
+	before := gitOutput(t, "rev-parse", "feature-1", "feature-2")
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+	if after := gitOutput(t, "rev-parse", "feature-1", "feature-2"); after != before {
+		t.Errorf("cascade changed branches with uncommitted changes")
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Errorf("cascade started with uncommitted changes")
+	}
+
+	// --autostash puts the changes aside and brings them back on feature-2
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Autostash: true}); err != nil {
+		t.Fatalf("cascadeRebase(--autostash) error = %v", err)
+	}
+	if !isAncestor(t, "main", "origin/feature-2") {
+		t.Errorf("feature-2 was not rebased with --autostash")
+	}
+	if current := gitOutput(t, "branch", "--show-current"); current != "feature-2" {
+		t.Errorf("current branch = %s, want feature-2", current)
+	}
+	if diff := gitOutput(t, "diff", "--name-only"); diff != "feature-2.txt" {
+		t.Errorf("uncommitted changes = %q, want feature-2.txt", diff)
+	}
+	if stashes := gitOutput(t, "stash", "list"); stashes != "" {
+		t.Errorf("stash was left behind: %s", stashes)
+	}
+}
+
+func TestCascadeRebaseAutostashAbort(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	pushConflictingMain(t)
+	writeFile(t, "feature-2.txt", "work in progress\n")
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Autostash: true}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	// Stashing something else while resolving the conflict buries the autostash
+	writeFile(t, "shared.txt", "resolved\n")
+	gitOutput(t, "add", "shared.txt")
+	gitOutput(t, "stash", "push", "--message", "mine")
+
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Abort: true}); err != nil {
+		t.Fatalf("cascadeRebase(--abort) error = %v", err)
+	}
+
+	if diff := gitOutput(t, "diff", "--name-only"); diff != "feature-2.txt" {
+		t.Errorf("uncommitted changes after abort = %q, want feature-2.txt", diff)
+	}
+	if stashes := gitOutput(t, "stash", "list", "--format=%s"); !strings.HasSuffix(stashes, "mine") || strings.Contains(stashes, "autostash") {
+		t.Errorf("stashes after abort = %q, want only the user's", stashes)
+	}
+}
+
+func TestCascadeRebaseWorktree(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	writeFile(t, "feature-2.txt", "work in progress\n")
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Worktree: true, Autostash: true}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	mainTip := gitOutput(t, "rev-parse", "main")
+	for _, branch := range []string{"feature-1", "feature-2", "origin/feature-1", "origin/feature-2"} {
+		if !isAncestor(t, mainTip, branch) {
+			t.Errorf("%s was not rebased onto main", branch)
+		}
+	}
+	if !isAncestor(t, "feature-1", "feature-2") {
+		t.Errorf("feature-2 was not rebased onto feature-1")
+	}
+
+	// The checkout stays on feature-2, now rebased, with the uncommitted change
+	if current := gitOutput(t, "branch", "--show-current"); current != "feature-2" {
+		t.Errorf("current branch = %s, want feature-2", current)
+	}
+	if head, want := gitOutput(t, "rev-parse", "HEAD"), gitOutput(t, "rev-parse", "feature-2"); head != want {
+		t.Errorf("HEAD = %s, want feature-2 at %s", head, want)
+	}
+	if diff := gitOutput(t, "status", "--porcelain"); diff != "M feature-2.txt" {
+		t.Errorf("status = %q, want only feature-2.txt modified", diff)
+	}
+
+	if worktrees := gitOutput(t, "worktree", "list", "--porcelain"); strings.Count(worktrees, "worktree ") != 1 {
+		t.Errorf("temporary worktree was left behind:\n%s", worktrees)
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Errorf("cascade state was not cleared")
+	}
+}
+
+func TestCascadeWorktreeAutostashFlags(t *testing.T) {
+	t.Cleanup(func() {
+		cascadeOpts = cascadeOptions{}
+		for _, name := range []string{"worktree", "autostash"} {
+			cascadeCmd.Flags().Lookup(name).Changed = false
+		}
+	})
+
+	// Stashing is how a worktree cascade gets past changes on the branch it rebases
+	if err := cascadeCmd.ParseFlags([]string{"--worktree", "--autostash"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cascadeCmd.ValidateFlagGroups(); err != nil {
+		t.Errorf("--worktree --autostash rejected: %v", err)
+	}
+	if !cascadeOpts.Worktree || !cascadeOpts.Autostash {
+		t.Errorf("cascade options = %+v, want worktree and autostash", cascadeOpts)
+	}
+}
+
+func TestCascadeRebaseWorktreeUncommittedChanges(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	writeFile(t, "feature-2.txt", "work in progress\n")
+	before := gitOutput(t, "rev-parse", "feature-2")
+
+	// Resetting the checked out branch to its rebased commit could clobber the changes
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Worktree: true}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+	if after := gitOutput(t, "rev-parse", "feature-2"); after != before {
+		t.Errorf("feature-2 moved to %s despite uncommitted changes", after)
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Errorf("cascade started despite uncommitted changes")
+	}
+	if diff := gitOutput(t, "status", "--porcelain"); diff != "M feature-2.txt" {
+		t.Errorf("status = %q, want only feature-2.txt modified", diff)
+	}
+
+	// A checkout outside the cascaded branches is left alone, changes and all
+	gitOutput(t, "stash")
+	gitOutput(t, "checkout", "feature-1")
+	commitFile(t, "feature-1.txt", "feature-1\n", "Amend feature 1")
+	writeFile(t, "feature-1.txt", "work in progress\n")
+	feature1 := gitOutput(t, "rev-parse", "feature-1")
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Worktree: true, Branch: "feature-2"}); err != nil {
+		t.Fatalf("cascadeRebase(--branch feature-2) error = %v", err)
+	}
+	if !isAncestor(t, feature1, "feature-2") {
+		t.Errorf("feature-2 was not rebased onto feature-1")
+	}
+	if after := gitOutput(t, "rev-parse", "feature-1"); after != feature1 {
+		t.Errorf("feature-1 moved to %s", after)
+	}
+	if diff := gitOutput(t, "status", "--porcelain"); diff != "M feature-1.txt" {
+		t.Errorf("status = %q, want only feature-1.txt modified", diff)
+	}
+}
+
+func TestCascadeRebaseWorktreeConflict(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	pushConflictingMain(t)
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Worktree: true}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	state, err := github.LoadCascadeState(ctx, repo)
+	if err != nil || state == nil || state.Worktree == "" {
+		t.Fatalf("cascade state = %+v, %v, want a conflict in a worktree", state, err)
+	}
+	if status := gitOutput(t, "status", "--porcelain"); status != "" {
+		t.Errorf("conflict leaked into the checkout:\n%s", status)
+	}
+
+	writeFileIn(t, state.Worktree, "shared.txt", "resolved\n")
+	runGit(t, state.Worktree, "add", "shared.txt")
+
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Continue: true}); err != nil {
+		t.Fatalf("cascadeRebase(--continue) error = %v", err)
+	}
+
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Errorf("cascade state was not cleared")
+	}
+	if !isAncestor(t, "main", "origin/feature-2") {
+		t.Errorf("feature-2 was not rebased after continuing")
+	}
+	if _, err := os.Stat(state.Worktree); !os.IsNotExist(err) {
+		t.Errorf("temporary worktree was not removed")
+	}
+}
+
+func TestCascadeRebaseAtomic(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	remote := filepath.Join(filepath.Dir(mustGetwd(t)), "remote.git")
+	pushedBefore := runGit(t, remote, "rev-parse", "feature-1", "feature-2")
+
+	// Main now conflicts with feature-2 only, so the cascade stops after rebasing feature-1
+	other := filepath.Join(filepath.Dir(mustGetwd(t)), "other")
+	writeFileIn(t, other, "feature-2.txt", "main\n")
+	runGit(t, other, "add", ".")
+	runGit(t, other, "commit", "-m", "Main conflicts with feature-2")
+	runGit(t, other, "push", "origin", "main")
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Atomic: true}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state == nil || state.NextStep().Branch != "feature-2" {
+		t.Fatalf("cascade did not stop at feature-2")
+	}
+	if !isAncestor(t, "main", "feature-1") {
+		t.Errorf("feature-1 was not rebased locally")
+	}
+	if pushed := runGit(t, remote, "rev-parse", "feature-1", "feature-2"); pushed != pushedBefore {
+		t.Errorf("branches were pushed before the cascade finished")
+	}
+
+	gitOutput(t, "checkout", "--theirs", "feature-2.txt")
+	gitOutput(t, "add", "feature-2.txt")
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Continue: true}); err != nil {
+		t.Fatalf("cascadeRebase(--continue) error = %v", err)
+	}
+
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Errorf("cascade state was not cleared")
+	}
+	if pushed, local := runGit(t, remote, "rev-parse", "feature-1", "feature-2"), gitOutput(t, "rev-parse", "feature-1", "feature-2"); pushed != local {
+		t.Errorf("rebased branches were not pushed")
+	}
+}
+
+func TestCascadeRebaseAtomicStaleLease(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	pushConflictingMain(t)
+	remote := filepath.Join(filepath.Dir(mustGetwd(t)), "remote.git")
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Atomic: true}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	// A teammate pushes to feature-2 while the conflict is being resolved
+	other := filepath.Join(filepath.Dir(mustGetwd(t)), "other")
+	runGit(t, other, "fetch", "origin", "feature-2")
+	runGit(t, other, "checkout", "-b", "feature-2", "origin/feature-2")
+	writeFileIn(t, other, "teammate.txt", "teammate\n")
+	runGit(t, other, "add", ".")
+	runGit(t, other, "commit", "-m", "Teammate change")
+	runGit(t, other, "push", "origin", "feature-2")
+	pushedBefore := runGit(t, remote, "rev-parse", "feature-1", "feature-2")
+
+	writeFile(t, "shared.txt", "resolved\n")
+	gitOutput(t, "add", "shared.txt")
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Continue: true}); err != nil {
+		t.Fatalf("cascadeRebase(--continue) error = %v", err)
+	}
+
+	if pushed := runGit(t, remote, "rev-parse", "feature-1", "feature-2"); pushed != pushedBefore {
+		t.Errorf("branches were pushed over the teammate's change")
+	}
+	state, err := github.LoadCascadeState(ctx, repo)
+	if err != nil || state == nil {
+		t.Fatalf("cascade state was cleared after the push failed: %v", err)
+	}
+
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Abort: true}); err != nil {
+		t.Fatalf("cascadeRebase(--abort) error = %v", err)
+	}
+	for _, step := range state.Steps {
+		if sha := gitOutput(t, "rev-parse", step.Branch); sha != step.OriginalSHA {
+			t.Errorf("%s = %s after abort, want %s", step.Branch, sha, step.OriginalSHA)
+		}
+	}
+}
+
+func TestCascadeRebaseConflictContinue(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	pushConflictingMain(t)
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	state, err := github.LoadCascadeState(ctx, repo)
+	if err != nil || state == nil {
+		t.Fatalf("cascade state was not saved after conflict: %v", err)
+	}
+	if step := state.NextStep(); step.Branch != "feature-1" || step.Status != github.StepConflict {
+		t.Fatalf("next step = %s (%s), want feature-1 (conflict)", step.Branch, step.Status)
+	}
+
+	// Starting a new cascade is refused while one is in progress
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state == nil || state.NextStep().Branch != "feature-1" {
+		t.Fatalf("in-progress cascade was replaced")
+	}
+
+	// Continuing before the conflict is resolved stops at it again
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Continue: true}); err != nil {
+		t.Fatalf("cascadeRebase(--continue) error = %v", err)
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state == nil || state.NextStep().Status != github.StepConflict {
+		t.Fatalf("unresolved conflict was not kept")
+	}
+
+	writeFile(t, "shared.txt", "resolved\n")
+	gitOutput(t, "add", "shared.txt")
+
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Continue: true}); err != nil {
+		t.Fatalf("cascadeRebase(--continue) error = %v", err)
+	}
+
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Errorf("cascade state was not cleared")
+	}
+	if !isAncestor(t, "main", "origin/feature-2") {
+		t.Errorf("feature-2 was not rebased after continuing")
+	}
+	if current := gitOutput(t, "branch", "--show-current"); current != "feature-2" {
+		t.Errorf("current branch = %s, want feature-2", current)
+	}
+}
+
+func TestCascadeRebaseContinueAfterGitRebaseAbort(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	pushConflictingMain(t)
+	remote := filepath.Join(filepath.Dir(mustGetwd(t)), "remote.git")
+	pushedBefore := runGit(t, remote, "rev-parse", "feature-1", "feature-2")
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	// Aborting the rebase by hand and switching away doesn't count as resolving it
+	gitOutput(t, "rebase", "--abort")
+	gitOutput(t, "checkout", "feature-2")
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Continue: true}); err != nil {
+		t.Fatalf("cascadeRebase(--continue) error = %v", err)
+	}
+	state, _ := github.LoadCascadeState(ctx, repo)
+	if state == nil || state.NextStep().Branch != "feature-1" || state.NextStep().Status != github.StepConflict {
+		t.Fatalf("rebase of feature-1 was not started over")
+	}
+	if current := gitOutput(t, "branch", "--show-current"); current != "" {
+		t.Errorf("current branch = %s, want the rebase of feature-1 in progress", current)
+	}
+	if pushed := runGit(t, remote, "rev-parse", "feature-1", "feature-2"); pushed != pushedBefore {
+		t.Errorf("branches were pushed without being rebased")
+	}
+
+	// A branch moved some other way is left for the user to sort out
+	gitOutput(t, "rebase", "--abort")
+	commitFile(t, "feature-1.txt", "more\n", "More feature 1")
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Continue: true}); err == nil {
+		t.Errorf("cascadeRebase(--continue) succeeded on a branch changed since the conflict")
+	}
+	if pushed := runGit(t, remote, "rev-parse", "feature-1", "feature-2"); pushed != pushedBefore {
+		t.Errorf("branches were pushed without being rebased")
+	}
+}
+
+func TestCascadeRebaseConflictSkip(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	pushConflictingMain(t)
+
+	feature1 := gitOutput(t, "rev-parse", "feature-1")
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Skip: true}); err != nil {
+		t.Fatalf("cascadeRebase(--skip) error = %v", err)
+	}
+
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Errorf("cascade state was not cleared")
+	}
+	if got := gitOutput(t, "rev-parse", "feature-1"); got != feature1 {
+		t.Errorf("skipped branch feature-1 moved from %s to %s", feature1, got)
+	}
+	if !isAncestor(t, "feature-1", "feature-2") {
+		t.Errorf("feature-2 is not based on feature-1")
+	}
+}
+
+func TestCascadeRebaseConflictAbort(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	pushConflictingMain(t)
+
+	before := gitOutput(t, "rev-parse", "feature-1", "feature-2")
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Abort: true}); err != nil {
+		t.Fatalf("cascadeRebase(--abort) error = %v", err)
+	}
+
+	if after := gitOutput(t, "rev-parse", "feature-1", "feature-2"); after != before {
+		t.Errorf("abort did not restore branches: got %s, want %s", after, before)
+	}
+	if current := gitOutput(t, "branch", "--show-current"); current != "feature-2" {
+		t.Errorf("current branch = %s, want feature-2", current)
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Errorf("cascade state was not cleared")
+	}
+}
+
+func TestCascadeRebaseNotInStack(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	gitOutput(t, "checkout", "main")
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Errorf("cascade started for a branch outside any stack")
+	}
+}
+
+// Helper functions
+
+func stackPRs() []*github.PR {
+	return []*github.PR{
+		{Number: 1, Title: "First", HeadRefName: "feature-1", BaseRefName: "main"},
+		{Number: 2, Title: "Second", HeadRefName: "feature-2", BaseRefName: "feature-1"},
+	}
+}
+
+// setupStackRepo creates a repository with a local bare remote and the stack
+// main ← feature-1 ← feature-2, then moves main ahead on the remote. The test
+// runs from a checkout of feature-2.
+func setupStackRepo(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	work := filepath.Join(dir, "work")
+
+	runGit(t, dir, "init", "--bare", "-b", "main", remote)
+	runGit(t, dir, "init", "-b", "main", work)
+	t.Chdir(work)
+
+	gitOutput(t, "config", "user.name", "Test")
+	gitOutput(t, "config", "user.email", "test@example.com")
+	gitOutput(t, "remote", "add", "origin", remote)
+
+	commitFile(t, "shared.txt", "base\n", "Initial commit")
+	gitOutput(t, "push", "-u", "origin", "main")
+
+	gitOutput(t, "checkout", "-b", "feature-1")
+	commitFile(t, "shared.txt", "feature-1\n", "Feature 1")
+	gitOutput(t, "push", "-u", "origin", "feature-1")
+
+	gitOutput(t, "checkout", "-b", "feature-2")
+	commitFile(t, "feature-2.txt", "feature-2\n", "Feature 2")
+	gitOutput(t, "push", "-u", "origin", "feature-2")
+
+	// Move main ahead on the remote from a second clone
+	other := filepath.Join(dir, "other")
+	runGit(t, dir, "clone", "-b", "main", remote, other)
+	runGit(t, other, "config", "user.name", "Test")
+	runGit(t, other, "config", "user.email", "test@example.com")
+	writeFileIn(t, other, "main.txt", "main\n")
+	runGit(t, other, "add", ".")
+	runGit(t, other, "commit", "-m", "Main moves")
+	runGit(t, other, "push", "origin", "main")
+
+	// Cascade despite predicted conflicts without asking
+	original := confirmConflicts
+	confirmConflicts = func() (bool, error) { return true, nil }
+	t.Cleanup(func() { confirmConflicts = original })
+}
+
+// pushConflictingMain pushes a change to main that conflicts with feature-1
+func pushConflictingMain(t *testing.T) {
+	t.Helper()
+
+	other := filepath.Join(filepath.Dir(mustGetwd(t)), "other")
+	writeFileIn(t, other, "shared.txt", "main\n")
+	runGit(t, other, "add", ".")
+	runGit(t, other, "commit", "-m", "Main conflicts")
+	runGit(t, other, "push", "origin", "main")
+}
+
+// pushTeammateChange pushes a commit to branch from the second clone, as a teammate would,
+// and returns the new head of the remote branch
+func pushTeammateChange(t *testing.T, branch string) string {
+	t.Helper()
+
+	other := filepath.Join(filepath.Dir(mustGetwd(t)), "other")
+	runGit(t, other, "fetch", "origin")
+	runGit(t, other, "checkout", branch)
+	writeFileIn(t, other, "teammate.txt", "teammate\n")
+	runGit(t, other, "add", ".")
+	runGit(t, other, "commit", "-m", "Teammate change")
+	runGit(t, other, "push", "origin", branch)
+	return runGit(t, other, "rev-parse", "HEAD")
+}
+
+func commitFile(t *testing.T, name, content, message string) {
+	t.Helper()
+	writeFile(t, name, content)
+	gitOutput(t, "add", name)
+	gitOutput(t, "commit", "-m", message)
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	writeFileIn(t, mustGetwd(t), name, content)
+}
+
+func writeFileIn(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustGetwd(t *testing.T) string {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wd
+}
+
+func isAncestor(t *testing.T, ancestor, descendant string) bool {
+	t.Helper()
+	return exec.Command("git", "merge-base", "--is-ancestor", ancestor, descendant).Run() == nil
+}
+
+func gitOutput(t *testing.T, args ...string) string {
+	t.Helper()
+	return runGit(t, "", args...)
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+	"github.com/vladimir-ananiev/gh-stack/pkg/github"
+)
+
+type checkOptions struct {
+	All bool
+}
+
+var checkOpts checkOptions
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Predict which branches a cascade would conflict on",
+	Long: `Fetch origin and simulate the rebases a cascade of the current stack would perform,
+in dependency order, each branch onto the simulated result of its parent. Branches that
+would conflict are listed with the files they conflict on.
+
+No branch, checkout or working tree is changed. Each branch's commits are simulated as a
+single merge, so a conflict that only an intermediate commit would hit can be missed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return checkStack(cmd.Context(), github.NewClient(), git.NewRepo(""), checkOpts)
+	},
+}
+
+func init() {
+	checkCmd.Flags().BoolVar(&checkOpts.All, "all", false, "Check every stack in the repository")
+
+	rootCmd.AddCommand(checkCmd)
+}
+
+func checkStack(ctx context.Context, client github.Client, repo git.Git, opts checkOptions) error {
+	currentBranch, err := repo.GetCurrentBranch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	prs, err := fetchOpenPRs(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	merged, err := fetchClosedPRs(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	tree, err := buildStackTree(ctx, repo, prs, merged)
+	if err != nil {
+		return err
+	}
+
+	roots := tree
+	if !opts.All {
+		currentTree := github.FindCurrentBranchTree(tree, currentBranch)
+		if currentTree == nil {
+			fmt.Printf("%s %s has no open PR or is not part of a stack\n\n",
+				errorStyle.Render("✗ Error:"),
+				warningStyle.Render(currentBranch))
+			fmt.Printf("%s Switch to a branch that has an open PR to use check, or use --all\n",
+				hintStyle.Render("Hint:"))
+			return nil
+		}
+		roots = []*github.TreeNode{currentTree}
+	}
+
+	predictions, err := predictConflicts(ctx, repo, roots, currentBranch, true, merged)
+	if errors.Is(err, git.ErrMergeTreeUnsupported) {
+		fmt.Printf("%s %v\n\n", errorStyle.Render("✗ Error:"), err)
+		fmt.Printf("%s Upgrade git to check for conflicts; 'git --version' shows the installed one\n",
+			hintStyle.Render("Hint:"))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	github.PrintConflictPredictions(predictions)
+	if github.HasConflicts(predictions) {
+		fmt.Printf("\n%s A cascade would stop at these conflicts; 'gh stack cascade' lets you resolve them\n",
+			hintStyle.Render("Hint:"))
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+	"github.com/vladimir-ananiev/gh-stack/pkg/github"
+)
+
+func TestPredictConflicts(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	tree := github.BuildDependencyTree(stackPRs())
+
+	predictions, err := predictConflicts(ctx, repo, tree, "feature-2", true, nil)
+	if err != nil {
+		t.Fatalf("predictConflicts() error = %v", err)
+	}
+	if github.HasConflicts(predictions) {
+		t.Errorf("predicted conflicts for a stack that rebases cleanly")
+	}
+
+	// Once main conflicts with feature-1, feature-2 can't be predicted either
+	pushConflictingMain(t)
+	before := gitOutput(t, "rev-parse", "main", "feature-1", "feature-2")
+
+	predictions, err = predictConflicts(ctx, repo, tree, "feature-2", true, nil)
+	if err != nil {
+		t.Fatalf("predictConflicts() error = %v", err)
+	}
+	if len(predictions) != 2 {
+		t.Fatalf("got %d predictions, want 2", len(predictions))
+	}
+	if conflicts := predictions[0].Conflicts; !reflect.DeepEqual(conflicts, []string{"shared.txt"}) {
+		t.Errorf("feature-1 conflicts = %v, want [shared.txt]", conflicts)
+	}
+	if blocker := predictions[1].BlockedBy; blocker != "feature-1" {
+		t.Errorf("feature-2 blocked by %q, want feature-1", blocker)
+	}
+
+	if after := gitOutput(t, "rev-parse", "main", "feature-1", "feature-2"); after != before {
+		t.Errorf("predicting conflicts moved branches")
+	}
+	if status := gitOutput(t, "status", "--porcelain"); status != "" {
+		t.Errorf("predicting conflicts touched the worktree:\n%s", status)
+	}
+}
+
+func TestCascadeRebaseDeclinedAfterPredictedConflict(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	pushConflictingMain(t)
+	confirmConflicts = func() (bool, error) { return false, nil }
+	before := gitOutput(t, "rev-parse", "feature-1", "feature-2")
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+
+	if after := gitOutput(t, "rev-parse", "feature-1", "feature-2"); after != before {
+		t.Errorf("declined cascade changed branches")
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Errorf("declined cascade was started")
+	}
+	if current := gitOutput(t, "branch", "--show-current"); current != "feature-2" {
+		t.Errorf("current branch = %s, want feature-2", current)
+	}
+}
+
+func TestCascadeRebaseWithoutConflictPrompt(t *testing.T) {
+	tests := []struct {
+		name string
+		opts cascadeOptions
+	}{
+		{name: "yes", opts: cascadeOptions{Yes: true}},
+		{name: "no check", opts: cascadeOptions{NoCheck: true}},
+		{name: "all", opts: cascadeOptions{All: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			setupStackRepo(t)
+			repo := git.NewRepo("")
+			pushConflictingMain(t)
+			confirmConflicts = func() (bool, error) {
+				t.Fatalf("asked to confirm predicted conflicts")
+				return false, nil
+			}
+
+			client := &github.FakeClient{Open: stackPRs()}
+			if err := cascadeRebase(ctx, client, repo, tt.opts); err != nil {
+				t.Fatalf("cascadeRebase() error = %v", err)
+			}
+
+			// A cascade that went ahead pulled main
+			if main, remote := gitOutput(t, "rev-parse", "main"), gitOutput(t, "rev-parse", "origin/main"); main != remote {
+				t.Errorf("cascade did not start")
+			}
+		})
+	}
+}
+
+// oldGitRepo is a repository whose git is too old to predict conflicts
+type oldGitRepo struct {
+	git.Git
+}
+
+func (oldGitRepo) SimulateRebase(context.Context, string, string, string) (string, []string, error) {
+	return "", nil, git.ErrMergeTreeUnsupported
+}
+
+func TestCascadeRebaseWithOldGit(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := oldGitRepo{git.NewRepo("")}
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := checkStack(ctx, client, repo, checkOptions{}); err != nil {
+		t.Errorf("checkStack() error = %v", err)
+	}
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{DryRun: true}); err != nil {
+		t.Errorf("cascadeRebase(--dry-run) error = %v", err)
+	}
+
+	// The cascade goes ahead without the prediction
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{}); err != nil {
+		t.Fatalf("cascadeRebase() error = %v", err)
+	}
+	if !isAncestor(t, "main", "origin/feature-2") {
+		t.Errorf("feature-2 was not rebased onto main")
+	}
+}
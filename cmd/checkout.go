@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh/spinner"
+	"github.com/spf13/cobra"
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+	"github.com/vladimir-ananiev/gh-stack/pkg/github"
+)
+
+var checkoutCmd = &cobra.Command{
+	Use:   "checkout [<number> | <branch>]",
+	Short: "Check out a branch of your stacks",
+	Long: `Without arguments, pick a branch from the stack tree interactively. Type / to filter
+by branch, title or PR number.
+
+With a PR number, check out its head branch, fetching it from origin if it isn't local. Any
+PR of the repository can be checked out this way, such as a teammate's.`,
+	Aliases: []string{"co"},
+	Args:    cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target := ""
+		if len(args) == 1 {
+			target = args[0]
+		}
+		return checkoutStackBranch(cmd.Context(), github.NewClient(), git.NewRepo(""), target)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkoutCmd)
+}
+
+// pickBranch asks which row of the stack tree to check out, returning "" when cancelled; replaced in tests
+var pickBranch = func(lines []github.TreeLine) (string, error) {
+	items := make([]list.Item, len(lines))
+	for i, line := range lines {
+		items[i] = pickerItem(line)
+	}
+
+	picker := list.New(items, pickerDelegate{}, 0, 0)
+	picker.Title = "Check out a branch"
+	picker.SetShowStatusBar(false)
+
+	result, err := tea.NewProgram(pickerModel{list: picker}, tea.WithAltScreen()).Run()
+	if err != nil {
+		return "", err
+	}
+	return result.(pickerModel).chosen, nil
+}
+
+func checkoutStackBranch(ctx context.Context, client github.Client, repo git.Git, target string) error {
+	currentBranch, err := repo.GetCurrentBranch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	branch := target
+	if target == "" {
+		prs, err := fetchOpenPRs(ctx, client)
+		if err != nil {
+			return err
+		}
+		closed, err := fetchClosedPRs(ctx, client)
+		if err != nil {
+			return err
+		}
+		tree, err := buildStackTree(ctx, repo, prs, closed)
+		if err != nil {
+			return err
+		}
+		if branch, err = pickBranch(github.TreeLines(tree, currentBranch)); err != nil {
+			return err
+		}
+		if branch == "" {
+			return nil
+		}
+	} else if number, err := strconv.Atoi(strings.TrimPrefix(target, "#")); err == nil {
+		// Any PR of the repository, not only the user's own
+		var pr *github.PR
+		err = spinner.New().
+			Title(fmt.Sprintf("Fetching #%d...", number)).
+			ActionWithErr(func(context.Context) error {
+				var err error
+				pr, err = client.GetPR(ctx, number)
+				return err
+			}).
+			Run()
+		if err != nil {
+			fmt.Printf("%s %v\n\n", errorStyle.Render("✗ Error:"), err)
+			fmt.Printf("%s Run 'gh stack' to see your open PRs\n", hintStyle.Render("Hint:"))
+			return nil
+		}
+		branch = pr.HeadRefName
+	}
+
+	if branch == currentBranch {
+		fmt.Printf("Already at %s\n", currentBranch)
+		return nil
+	}
+
+	if _, err := repo.GetBranchSHA(ctx, branch); err != nil {
+		if err := repo.FetchBranch(ctx, branch); err != nil {
+			return err
+		}
+		fmt.Printf("%s %s from origin\n", completedStyle.Render("✓ Fetched"), branch)
+	}
+
+	if err := repo.CheckoutBranch(ctx, branch); err != nil {
+		return err
+	}
+	fmt.Printf("%s %s\n", completedStyle.Render("✓ Checked out"), branch)
+	return nil
+}
+
+// pickerItem is a row of the stack tree in the checkout picker
+type pickerItem github.TreeLine
+
+func (i pickerItem) FilterValue() string {
+	if i.PR == nil {
+		return i.Branch
+	}
+	return fmt.Sprintf("%s #%d %s", i.Branch, i.PR.Number, i.PR.Title)
+}
+
+// pickerDelegate renders rows as the tree PrintTree shows, with a cursor on the selected one
+type pickerDelegate struct{}
+
+func (d pickerDelegate) Height() int                               { return 1 }
+func (d pickerDelegate) Spacing() int                              { return 0 }
+func (d pickerDelegate) Update(msg tea.Msg, m *list.Model) tea.Cmd { return nil }
+
+func (d pickerDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	cursor := "  "
+	if index == m.Index() {
+		cursor = completedStyle.Render("> ")
+	}
+	fmt.Fprint(w, cursor+item.(pickerItem).Text)
+}
+
+type pickerModel struct {
+	list   list.Model
+	chosen string
+}
+
+func (m pickerModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m pickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.list.SetSize(msg.Width, msg.Height)
+	case tea.KeyMsg:
+		if msg.String() == "enter" && m.list.FilterState() != list.Filtering {
+			if item, ok := m.list.SelectedItem().(pickerItem); ok {
+				m.chosen = item.Branch
+			}
+			return m, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m pickerModel) View() string {
+	return m.list.View()
+}
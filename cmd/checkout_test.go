@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+	"github.com/vladimir-ananiev/gh-stack/pkg/github"
+)
+
+func TestCheckoutStackBranchByNumber(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+
+	// feature-1 only exists on the remote
+	gitOutput(t, "branch", "-D", "feature-1")
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := checkoutStackBranch(ctx, client, repo, "#1"); err != nil {
+		t.Fatalf("checkoutStackBranch() error = %v", err)
+	}
+
+	if current := gitOutput(t, "branch", "--show-current"); current != "feature-1" {
+		t.Errorf("current branch = %s, want feature-1", current)
+	}
+	if upstream := gitOutput(t, "rev-parse", "--abbrev-ref", "@{upstream}"); upstream != "origin/feature-1" {
+		t.Errorf("upstream = %s, want origin/feature-1", upstream)
+	}
+
+	// Unknown PRs leave the checkout alone
+	if err := checkoutStackBranch(ctx, client, repo, "42"); err != nil {
+		t.Fatalf("checkoutStackBranch() error = %v", err)
+	}
+	if current := gitOutput(t, "branch", "--show-current"); current != "feature-1" {
+		t.Errorf("current branch = %s, want feature-1", current)
+	}
+}
+
+// lookupOnlyClient fails if the PR list is fetched, as checking out a number or branch
+// shouldn't need it
+type lookupOnlyClient struct {
+	*github.FakeClient
+}
+
+func (c lookupOnlyClient) GetOpenPRs(ctx context.Context, limit int) ([]*github.PR, bool, error) {
+	return nil, false, errors.New("unexpected PR list fetch")
+}
+
+func (c lookupOnlyClient) GetClosedPRs(ctx context.Context) ([]*github.PR, error) {
+	return nil, errors.New("unexpected PR list fetch")
+}
+
+func TestCheckoutStackBranchWithoutPRList(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+
+	// A teammate's PR isn't among the user's open PRs but can still be checked out
+	client := lookupOnlyClient{&github.FakeClient{Open: stackPRs()}}
+	if err := checkoutStackBranch(ctx, client, repo, "1"); err != nil {
+		t.Fatalf("checkoutStackBranch() error = %v", err)
+	}
+	if current := gitOutput(t, "branch", "--show-current"); current != "feature-1" {
+		t.Errorf("current branch = %s, want feature-1", current)
+	}
+
+	if err := checkoutStackBranch(ctx, client, repo, "feature-2"); err != nil {
+		t.Fatalf("checkoutStackBranch() error = %v", err)
+	}
+	if current := gitOutput(t, "branch", "--show-current"); current != "feature-2" {
+		t.Errorf("current branch = %s, want feature-2", current)
+	}
+}
+
+func TestCheckoutStackBranchPicker(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+
+	var offered []string
+	original := pickBranch
+	t.Cleanup(func() { pickBranch = original })
+	pickBranch = func(lines []github.TreeLine) (string, error) {
+		for _, line := range lines {
+			offered = append(offered, line.Branch)
+		}
+		return "feature-1", nil
+	}
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := checkoutStackBranch(ctx, client, repo, ""); err != nil {
+		t.Fatalf("checkoutStackBranch() error = %v", err)
+	}
+
+	if len(offered) != 3 {
+		t.Errorf("offered %v, want main, feature-1 and feature-2", offered)
+	}
+	if current := gitOutput(t, "branch", "--show-current"); current != "feature-1" {
+		t.Errorf("current branch = %s, want feature-1", current)
+	}
+}
+
+func TestPickerModelSelect(t *testing.T) {
+	lines := []github.TreeLine{
+		{Branch: "main", Text: "main"},
+		{Branch: "feature-1", PR: &github.PR{Number: 1, Title: "First"}, Text: "└── feature-1"},
+	}
+	items := []list.Item{pickerItem(lines[0]), pickerItem(lines[1])}
+
+	var model tea.Model = pickerModel{list: list.New(items, pickerDelegate{}, 80, 10)}
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyDown})
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if chosen := model.(pickerModel).chosen; chosen != "feature-1" {
+		t.Errorf("chosen = %q, want feature-1", chosen)
+	}
+	if cmd == nil {
+		t.Errorf("picker did not quit after a selection")
+	}
+}
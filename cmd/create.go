@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+)
+
+var createMessage string
+
+var createCmd = &cobra.Command{
+	Use:   "create <branch>",
+	Short: "Create a new branch stacked on the current one",
+	Long: `Create a branch off the current branch and check it out, recording the current
+branch as its parent so it shows up in the stack before any PR exists.
+
+With --message, the staged changes are committed to the new branch.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return createBranch(cmd.Context(), git.NewRepo(""), args[0], createMessage)
+	},
+}
+
+func init() {
+	createCmd.Flags().StringVarP(&createMessage, "message", "m", "", "Commit the staged changes with this message")
+
+	rootCmd.AddCommand(createCmd)
+}
+
+func createBranch(ctx context.Context, repo git.Git, branch, message string) error {
+	parent, err := repo.GetCurrentBranch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	if message != "" {
+		staged, err := repo.HasStagedChanges(ctx)
+		if err != nil {
+			return err
+		}
+		if !staged {
+			fmt.Printf("%s no staged changes to commit\n\n", errorStyle.Render("✗ Error:"))
+			fmt.Printf("%s Stage changes with 'git add', or drop --message to only create the branch\n",
+				hintStyle.Render("Hint:"))
+			return nil
+		}
+	}
+
+	parentTip, err := repo.GetBranchSHA(ctx, parent)
+	if err != nil {
+		return err
+	}
+
+	if err := repo.CreateBranch(ctx, branch); err != nil {
+		return err
+	}
+	if err := repo.SetStackParent(ctx, branch, git.StackParent{Branch: parent, Tip: parentTip}); err != nil {
+		return err
+	}
+
+	if message != "" {
+		if err := repo.Commit(ctx, message); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("%s %s on top of %s\n", completedStyle.Render("✓ Created"), branch, parent)
+	return nil
+}
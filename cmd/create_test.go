@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+	"github.com/vladimir-ananiev/gh-stack/pkg/github"
+)
+
+func TestCreateBranch(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+
+	parentSHA := gitOutput(t, "rev-parse", "feature-2")
+	writeFile(t, "feature-3.txt", "feature-3\n")
+	gitOutput(t, "add", "feature-3.txt")
+
+	if err := createBranch(ctx, repo, "feature-3", "Feature 3"); err != nil {
+		t.Fatalf("createBranch() error = %v", err)
+	}
+
+	if current := gitOutput(t, "branch", "--show-current"); current != "feature-3" {
+		t.Errorf("current branch = %s, want feature-3", current)
+	}
+	if parent := gitOutput(t, "config", "branch.feature-3.stackParent"); parent != "feature-2" {
+		t.Errorf("recorded parent = %s, want feature-2", parent)
+	}
+	if tip := gitOutput(t, "config", "branch.feature-3.stackParentTip"); tip != parentSHA {
+		t.Errorf("recorded parent tip = %s, want %s", tip, parentSHA)
+	}
+	if got := gitOutput(t, "rev-parse", "feature-3^"); got != parentSHA {
+		t.Errorf("feature-3 was not committed on top of feature-2")
+	}
+	if got := gitOutput(t, "rev-parse", "feature-2"); got != parentSHA {
+		t.Errorf("feature-2 moved to %s", got)
+	}
+
+	// The new branch joins the stack before it has a PR
+	tree, err := buildStackTree(ctx, repo, stackPRs(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := github.FindBranchNode(tree, "feature-3")
+	if node == nil || !node.PR.IsLocal || node.PR.BaseRefName != "feature-2" {
+		t.Fatalf("feature-3 is not in the stack under feature-2")
+	}
+	if parent := github.FindBranchNode(tree, "feature-2"); len(parent.Children) != 1 || parent.Children[0] != node {
+		t.Errorf("feature-3 is not a child of feature-2")
+	}
+}
+
+func TestCreateBranchNothingStaged(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+
+	if err := createBranch(ctx, repo, "feature-3", "Feature 3"); err != nil {
+		t.Fatalf("createBranch() error = %v", err)
+	}
+
+	if current := gitOutput(t, "branch", "--show-current"); current != "feature-2" {
+		t.Errorf("branch was created with nothing to commit")
+	}
+}
@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/huh/spinner"
+	"github.com/spf13/cobra"
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+	"github.com/vladimir-ananiev/gh-stack/pkg/github"
+)
+
+type mergeOptions struct {
+	Method  string
+	Stack   bool
+	Timeout time.Duration
+	Force   bool
+}
+
+var mergeOpts mergeOptions
+
+// pollInterval is how long to wait between checks on a PR being merged or tested
+var pollInterval = 10 * time.Second
+
+// checksGracePeriod is how long a restacked head may go without any checks before it is taken to have none
+var checksGracePeriod = 2 * time.Minute
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge",
+	Short: "Merge the bottom PR of the current stack and restack the rest",
+	Long: `Land the root PR of the stack containing the current branch:
+	1. Merge it with the repository's preferred merge method, or --method
+	2. Wait until GitHub reports it merged, e.g. when it goes through a merge queue
+	3. Retarget its children onto its base and rebase them, replaying only their own commits
+	4. Offer to delete the merged local branch
+
+With --stack, keep landing the next PR once its checks have finished, as long as
+it is approved and they pass. Landing stops where the stack forks.
+
+A restacked PR whose checks haven't reported within a couple of minutes is taken
+to have no CI. Waiting for a PR to be merged or for its checks gives up after
+--timeout.
+
+Nothing is merged while uncommitted changes would block restacking the children, or
+while a child branch differs from its remote, unless --force is given.
+
+Conflicts are resolved the same way as in cascade, with 'gh stack cascade --continue'.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return mergeStack(cmd.Context(), github.NewClient(), git.NewRepo(""), mergeOpts)
+	},
+}
+
+func init() {
+	mergeCmd.Flags().StringVar(&mergeOpts.Method, "method", "", "Merge method: squash, merge or rebase (default: the first the repository allows)")
+	mergeCmd.Flags().BoolVar(&mergeOpts.Stack, "stack", false, "Keep landing the next PR while it is approved and its checks pass")
+	mergeCmd.Flags().DurationVar(&mergeOpts.Timeout, "timeout", 30*time.Minute, "How long to wait for a PR to be merged or for its checks to finish")
+	mergeCmd.Flags().BoolVar(&mergeOpts.Force, "force", false, "Restack even if branches have diverged from their remote")
+
+	rootCmd.AddCommand(mergeCmd)
+}
+
+func mergeStack(ctx context.Context, client github.Client, repo git.Git, opts mergeOptions) error {
+	state, err := github.LoadCascadeState(ctx, repo)
+	if err != nil {
+		return err
+	}
+	if state != nil {
+		fmt.Printf("%s a cascade is already in progress\n\n", errorStyle.Render("✗ Error:"))
+		fmt.Printf("%s Use 'gh stack cascade --continue', '--skip' or '--abort'\n",
+			hintStyle.Render("Hint:"))
+		return nil
+	}
+
+	currentBranch, err := repo.GetCurrentBranch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	methods, err := client.GetMergeMethods(ctx)
+	if err != nil {
+		return err
+	}
+	method := opts.Method
+	if method == "" && len(methods) > 0 {
+		method = methods[0]
+	}
+	if !slices.Contains(methods, method) {
+		fmt.Printf("%s %s merges are not allowed in this repository\n\n", errorStyle.Render("✗ Error:"), method)
+		fmt.Printf("%s Use --method with one of: %s\n", hintStyle.Render("Hint:"), strings.Join(methods, ", "))
+		return nil
+	}
+
+	open, err := fetchOpenPRs(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	closed, err := fetchClosedPRs(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	tree, err := buildStackTree(ctx, repo, open, closed)
+	if err != nil {
+		return err
+	}
+	root := github.FindCurrentBranchTree(tree, currentBranch)
+	if root == nil {
+		fmt.Printf("%s %s has no open PR or is not part of a stack\n\n",
+			errorStyle.Render("✗ Error:"),
+			warningStyle.Render(currentBranch))
+		fmt.Printf("%s Switch to a branch that has an open PR to use merge\n",
+			hintStyle.Render("Hint:"))
+		return nil
+	}
+	if root.PR.IsLocal {
+		fmt.Printf("%s %s has no PR yet\n\n", errorStyle.Render("✗ Error:"), warningStyle.Render(root.PR.HeadRefName))
+		fmt.Printf("%s Open PRs for the stack with 'gh stack submit'\n", hintStyle.Render("Hint:"))
+		return nil
+	}
+
+	// Landing the PR can't be undone, so check its children can be restacked first
+	if ok, err := checkRestack(ctx, repo, root.Children, opts.Force); err != nil || !ok {
+		return err
+	}
+
+	var merged []*github.PR
+	for {
+		pr, err := landPR(ctx, client, root.PR, method, opts.Timeout)
+		if err != nil {
+			return err
+		}
+		merged = append(merged, pr)
+
+		// Children of the merged PR now target its base
+		var orphans []*github.Orphan
+		for _, child := range root.Children {
+			// The tree holds copies of the PRs, retarget the fetched ones the restack builds on
+			childPR := child.PR
+			if i := slices.IndexFunc(open, func(open *github.PR) bool { return open.HeadRefName == childPR.HeadRefName }); i >= 0 {
+				childPR = open[i]
+			}
+			orphans = append(orphans, &github.Orphan{PR: childPR, Parent: pr, NewBase: pr.BaseRefName})
+		}
+		open = slices.DeleteFunc(open, func(open *github.PR) bool { return open.Number == pr.Number })
+
+		if len(orphans) > 0 {
+			if err := restackOrphans(ctx, client, repo, open, orphans, currentBranch, merged); err != nil {
+				return err
+			}
+
+			// A conflict leaves the cascade in progress
+			if state, err := github.LoadCascadeState(ctx, repo); err != nil || state != nil {
+				return err
+			}
+		}
+
+		if !opts.Stack || len(root.Children) == 0 {
+			break
+		}
+		if len(root.Children) > 1 {
+			fmt.Printf("%s the stack forks after #%d, land each branch separately\n",
+				hintStyle.Render("Hint:"), pr.Number)
+			break
+		}
+
+		next := root.Children[0]
+		if next.PR.IsLocal {
+			fmt.Printf("%s stopping before %s: it has no PR yet\n", hintStyle.Render("Hint:"), next.PR.HeadRefName)
+			break
+		}
+		ready, err := waitForChecks(ctx, client, repo, next.PR, opts.Timeout)
+		if err != nil {
+			return err
+		}
+		if blocker := github.MergeBlocker(ready); blocker != "" {
+			fmt.Printf("%s stopping before #%d: %s\n", hintStyle.Render("Hint:"), ready.Number, blocker)
+			break
+		}
+		root = next
+	}
+
+	return deleteMergedBranches(ctx, repo, mergedBranches(merged), currentBranch)
+}
+
+// landPR merges a PR and waits until GitHub reports it merged, returning its final state
+func landPR(ctx context.Context, client github.Client, pr *github.PR, method string, timeout time.Duration) (*github.PR, error) {
+	err := spinner.New().
+		Title(fmt.Sprintf("Merging #%d (%s)...", pr.Number, method)).
+		ActionWithErr(func(context.Context) error {
+			return client.MergePR(ctx, pr.Number, method)
+		}).
+		Run()
+	if err != nil {
+		return nil, err
+	}
+
+	var landed *github.PR
+	err = spinner.New().
+		Title(fmt.Sprintf("Waiting for #%d to be merged...", pr.Number)).
+		ActionWithErr(func(context.Context) error {
+			var err error
+			landed, err = pollPR(ctx, client, pr.Number, timeout, "to be merged", func(pr *github.PR) bool { return pr.State != "OPEN" })
+			return err
+		}).
+		Run()
+	if err != nil {
+		return nil, err
+	}
+	if landed.State != "MERGED" {
+		return nil, fmt.Errorf("#%d was closed without being merged", pr.Number)
+	}
+
+	fmt.Printf("%s #%d %s\n", completedStyle.Render("✓ Merged"), landed.Number, landed.Title)
+	return landed, nil
+}
+
+// waitForChecks waits for GitHub to see the restacked head of pr and finish running its checks.
+// A head without any checks after checksGracePeriod is reported as having none.
+func waitForChecks(ctx context.Context, client github.Client, repo git.Git, pr *github.PR, timeout time.Duration) (*github.PR, error) {
+	head, err := repo.GetBranchSHA(ctx, pr.HeadRefName)
+	if err != nil {
+		return nil, err
+	}
+
+	var checked *github.PR
+	var noChecksSince time.Time
+	err = spinner.New().
+		Title(fmt.Sprintf("Waiting for checks on #%d...", pr.Number)).
+		ActionWithErr(func(context.Context) error {
+			var err error
+			checked, err = pollPR(ctx, client, pr.Number, timeout, "to finish its checks", func(pr *github.PR) bool {
+				if pr.HeadRefOid != "" && pr.HeadRefOid != head {
+					return false
+				}
+				if pr.CheckState != "" {
+					return pr.CheckState != github.ChecksPending
+				}
+
+				// GitHub reports no checks until CI picks up a new head, only take it as having none after a while
+				if noChecksSince.IsZero() {
+					noChecksSince = time.Now()
+				}
+				if time.Since(noChecksSince) < checksGracePeriod {
+					return false
+				}
+				pr.CheckState = github.ChecksNone
+				return true
+			})
+			return err
+		}).
+		Run()
+	return checked, err
+}
+
+// pollPR gets the PR every pollInterval until done reports true, giving up after timeout.
+// condition describes what is being waited for in the error.
+func pollPR(ctx context.Context, client github.Client, number int, timeout time.Duration, condition string, done func(*github.PR) bool) (*github.PR, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		pr, err := client.GetPR(ctx, number)
+		if err != nil {
+			return nil, err
+		}
+		if done(pr) {
+			return pr, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out after %s waiting for #%d %s", timeout, number, condition)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
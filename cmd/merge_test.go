@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+	"github.com/vladimir-ananiev/gh-stack/pkg/github"
+)
+
+func TestMergeStack(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	feature1 := squashMergeOnRemote(t, "feature-1")
+
+	prs := stackPRs()
+	prs[0].HeadRefOid = feature1
+	client := &github.FakeClient{Open: prs, MergeMethods: []string{github.MergeMethodSquash, github.MergeMethodMerge}}
+	if err := mergeStack(ctx, client, repo, mergeOptions{}); err != nil {
+		t.Fatalf("mergeStack() error = %v", err)
+	}
+
+	if expected := map[int]string{1: github.MergeMethodSquash}; !reflect.DeepEqual(client.Merged, expected) {
+		t.Errorf("merged = %v, want %v", client.Merged, expected)
+	}
+	if base := client.Open[0].BaseRefName; base != "main" {
+		t.Errorf("#2 base = %s, want main", base)
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Fatalf("restack stopped with a conflict")
+	}
+	if !isAncestor(t, "main", "origin/feature-2") {
+		t.Errorf("feature-2 was not rebased onto main")
+	}
+	if count := gitOutput(t, "rev-list", "--count", "main..feature-2"); count != "1" {
+		t.Errorf("feature-2 has %s commits on top of main, want 1", count)
+	}
+}
+
+func TestMergeStackLandsNextPR(t *testing.T) {
+	tests := []struct {
+		name           string
+		reviewDecision string
+		checkState     string
+		gracePeriod    time.Duration
+		expected       map[int]string
+		expectedErr    string
+	}{
+		{
+			name:           "approved and green",
+			reviewDecision: "APPROVED",
+			checkState:     github.ChecksPassing,
+			expected:       map[int]string{1: github.MergeMethodMerge, 2: github.MergeMethodMerge},
+		},
+		{
+			name:           "not approved",
+			reviewDecision: "REVIEW_REQUIRED",
+			checkState:     github.ChecksPassing,
+			expected:       map[int]string{1: github.MergeMethodMerge},
+		},
+		{
+			name:           "failing checks",
+			reviewDecision: "APPROVED",
+			checkState:     github.ChecksFailing,
+			expected:       map[int]string{1: github.MergeMethodMerge},
+		},
+		{
+			name:           "checks not reported",
+			reviewDecision: "APPROVED",
+			checkState:     "",
+			gracePeriod:    time.Hour,
+			expected:       map[int]string{1: github.MergeMethodMerge},
+			expectedErr:    "timed out after 50ms waiting for #2 to finish its checks",
+		},
+		{
+			name:           "no checks after the grace period",
+			reviewDecision: "APPROVED",
+			checkState:     "",
+			expected:       map[int]string{1: github.MergeMethodMerge, 2: github.MergeMethodMerge},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			setupStackRepo(t)
+			repo := git.NewRepo("")
+			feature1 := squashMergeOnRemote(t, "feature-1")
+			setPolling(t, time.Millisecond, tt.gracePeriod)
+
+			prs := stackPRs()
+			prs[0].HeadRefOid = feature1
+			prs[1].ReviewDecision = tt.reviewDecision
+			prs[1].CheckState = tt.checkState
+			client := &github.FakeClient{Open: prs}
+
+			opts := mergeOptions{Method: github.MergeMethodMerge, Stack: true, Timeout: 50 * time.Millisecond}
+			err := mergeStack(ctx, client, repo, opts)
+			if tt.expectedErr == "" && err != nil {
+				t.Fatalf("mergeStack() error = %v", err)
+			}
+			if tt.expectedErr != "" && (err == nil || err.Error() != tt.expectedErr) {
+				t.Fatalf("mergeStack() error = %v, want %q", err, tt.expectedErr)
+			}
+
+			if !reflect.DeepEqual(client.Merged, tt.expected) {
+				t.Errorf("merged = %v, want %v", client.Merged, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMergeStackChecksRestackFirst(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(t *testing.T) string
+		opts     mergeOptions
+		expected map[int]string
+	}{
+		{
+			name: "diverged child",
+			setup: func(t *testing.T) string {
+				return pushTeammateChange(t, "feature-2")
+			},
+		},
+		{
+			name: "diverged child with --force",
+			setup: func(t *testing.T) string {
+				return pushTeammateChange(t, "feature-2")
+			},
+			opts:     mergeOptions{Force: true},
+			expected: map[int]string{1: github.MergeMethodMerge},
+		},
+		{
+			name: "uncommitted changes",
+			setup: func(t *testing.T) string {
+				writeFile(t, "feature-2.txt", "work in progress\n")
+				return ""
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			setupStackRepo(t)
+			repo := git.NewRepo("")
+			feature1 := squashMergeOnRemote(t, "feature-1")
+			feature2 := tt.setup(t)
+
+			prs := stackPRs()
+			prs[0].HeadRefOid = feature1
+			prs[1].HeadRefOid = feature2
+			client := &github.FakeClient{Open: prs}
+
+			opts := tt.opts
+			opts.Method = github.MergeMethodMerge
+			if err := mergeStack(ctx, client, repo, opts); err != nil {
+				t.Fatalf("mergeStack() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(client.Merged, tt.expected) {
+				t.Errorf("merged = %v, want %v", client.Merged, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMergeStackMethodNotAllowed(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+
+	client := &github.FakeClient{Open: stackPRs(), MergeMethods: []string{github.MergeMethodMerge}}
+	if err := mergeStack(ctx, client, repo, mergeOptions{Method: github.MergeMethodSquash}); err != nil {
+		t.Fatalf("mergeStack() error = %v", err)
+	}
+
+	if len(client.Merged) != 0 {
+		t.Errorf("merged %v with a disallowed method", client.Merged)
+	}
+}
+
+// setPolling shortens how often and how long merge polls PRs for the test
+func setPolling(t *testing.T, interval, gracePeriod time.Duration) {
+	t.Helper()
+	originalInterval, originalGracePeriod := pollInterval, checksGracePeriod
+	pollInterval, checksGracePeriod = interval, gracePeriod
+	t.Cleanup(func() { pollInterval, checksGracePeriod = originalInterval, originalGracePeriod })
+}
+
+// squashMergeOnRemote squashes branch into main on the remote, as GitHub would when its PR is merged,
+// deletes the local branch and returns the branch's head
+func squashMergeOnRemote(t *testing.T, branch string) string {
+	t.Helper()
+
+	head := gitOutput(t, "rev-parse", branch)
+	other := filepath.Join(filepath.Dir(mustGetwd(t)), "other")
+	runGit(t, other, "fetch", "origin")
+	runGit(t, other, "merge", "--squash", "origin/"+branch)
+	runGit(t, other, "commit", "-m", "Squash "+branch)
+	runGit(t, other, "push", "origin", "main")
+	gitOutput(t, "branch", "-D", branch)
+	return head
+}
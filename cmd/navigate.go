@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/huh"
+	"github.com/spf13/cobra"
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+	"github.com/vladimir-ananiev/gh-stack/pkg/github"
+)
+
+// direction is where a navigation command moves within the stack
+type direction int
+
+const (
+	directionUp direction = iota
+	directionDown
+	directionTop
+	directionBottom
+)
+
+var navigateSteps int
+
+var upCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Check out the child of the current branch",
+	Long: `Check out the branch stacked on the current one, moving away from the base branch.
+When there are several children, pick one interactively.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return navigateStack(cmd.Context(), github.NewClient(), git.NewRepo(""), directionUp, navigateSteps)
+	},
+}
+
+var downCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Check out the parent of the current branch",
+	Long: `Check out the branch the current one is stacked on, moving towards the base branch.
+The bottom branch of a stack moves down to the base branch itself.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return navigateStack(cmd.Context(), github.NewClient(), git.NewRepo(""), directionDown, navigateSteps)
+	},
+}
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Check out the topmost branch of the current stack",
+	Long: `Follow the children of the current branch up to the last branch of the stack.
+When the stack forks, pick which way to go interactively.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return navigateStack(cmd.Context(), github.NewClient(), git.NewRepo(""), directionTop, 0)
+	},
+}
+
+var bottomCmd = &cobra.Command{
+	Use:   "bottom",
+	Short: "Check out the bottom branch of the current stack",
+	Long:  `Check out the first branch of the current stack, the one based on the base branch.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return navigateStack(cmd.Context(), github.NewClient(), git.NewRepo(""), directionBottom, 0)
+	},
+}
+
+func init() {
+	upCmd.Flags().IntVarP(&navigateSteps, "steps", "n", 1, "Number of levels to move")
+	downCmd.Flags().IntVarP(&navigateSteps, "steps", "n", 1, "Number of levels to move")
+
+	rootCmd.AddCommand(upCmd, downCmd, topCmd, bottomCmd)
+}
+
+// pickChild asks which child to move to when a branch has several; replaced in tests
+var pickChild = func(branch string, children []*github.TreeNode) (*github.TreeNode, error) {
+	options := make([]huh.Option[*github.TreeNode], len(children))
+	for i, child := range children {
+		options[i] = huh.NewOption(branchLabel(child.PR), child)
+	}
+
+	var picked *github.TreeNode
+	err := huh.NewSelect[*github.TreeNode]().
+		Title(fmt.Sprintf("%s has several children, which one?", branch)).
+		Options(options...).
+		Value(&picked).
+		Run()
+	return picked, err
+}
+
+// branchLabel describes a stack entry in a single line of plain text
+func branchLabel(pr *github.PR) string {
+	if pr.IsLocal {
+		return pr.HeadRefName + " (no PR)"
+	}
+	return fmt.Sprintf("%s #%d %s", pr.HeadRefName, pr.Number, pr.Title)
+}
+
+func navigateStack(ctx context.Context, client github.Client, repo git.Git, dir direction, steps int) error {
+	if steps < 1 {
+		steps = 1
+	}
+
+	currentBranch, err := repo.GetCurrentBranch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	prs, err := fetchOpenPRs(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	closed, err := fetchClosedPRs(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	tree, err := buildStackTree(ctx, repo, prs, closed)
+	if err != nil {
+		return err
+	}
+
+	// On a base branch, the stacks based on it are its children
+	path := github.FindBranchPath(tree, currentBranch)
+	var children []*github.TreeNode
+	if path != nil {
+		children = path[len(path)-1].Children
+	} else {
+		for _, root := range tree {
+			if root.PR.BaseRefName == currentBranch {
+				children = append(children, root)
+			}
+		}
+	}
+	if path == nil && len(children) == 0 {
+		fmt.Printf("%s %s is not part of any stack\n\n", errorStyle.Render("✗ Error:"), warningStyle.Render(currentBranch))
+		fmt.Printf("%s Run 'gh stack' to see your stacks\n", hintStyle.Render("Hint:"))
+		return nil
+	}
+
+	target := currentBranch
+	switch dir {
+	case directionUp, directionTop:
+		for moved := 0; len(children) > 0 && (dir == directionTop || moved < steps); moved++ {
+			next := children[0]
+			if len(children) > 1 {
+				if next, err = pickChild(target, children); err != nil {
+					return err
+				}
+			}
+			target, children = next.PR.HeadRefName, next.Children
+		}
+	case directionDown:
+		if path != nil {
+			if index := len(path) - 1 - steps; index >= 0 {
+				target = path[index].PR.HeadRefName
+			} else {
+				target = path[0].PR.BaseRefName
+			}
+		}
+	case directionBottom:
+		if path != nil {
+			target = path[0].PR.HeadRefName
+		} else if len(children) == 1 {
+			target = children[0].PR.HeadRefName
+		} else if next, err := pickChild(target, children); err != nil {
+			return err
+		} else {
+			target = next.PR.HeadRefName
+		}
+	}
+
+	if target == currentBranch {
+		fmt.Printf("Already at %s\n", currentBranch)
+		return nil
+	}
+
+	if err := repo.CheckoutBranch(ctx, target); err != nil {
+		return err
+	}
+	fmt.Printf("%s %s\n", completedStyle.Render("✓ Checked out"), target)
+	return nil
+}
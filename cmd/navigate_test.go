@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+	"github.com/vladimir-ananiev/gh-stack/pkg/github"
+)
+
+func TestNavigateStack(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	client := &github.FakeClient{Open: stackPRs()}
+
+	tests := []struct {
+		name     string
+		from     string
+		dir      direction
+		steps    int
+		expected string
+	}{
+		{name: "down to parent", from: "feature-2", dir: directionDown, steps: 1, expected: "feature-1"},
+		{name: "down past the bottom stops at the base", from: "feature-2", dir: directionDown, steps: 5, expected: "main"},
+		{name: "bottom", from: "feature-2", dir: directionBottom, expected: "feature-1"},
+		{name: "up from the base", from: "main", dir: directionUp, steps: 1, expected: "feature-1"},
+		{name: "up several levels", from: "main", dir: directionUp, steps: 2, expected: "feature-2"},
+		{name: "up from the top stays", from: "feature-2", dir: directionUp, steps: 1, expected: "feature-2"},
+		{name: "top", from: "feature-1", dir: directionTop, expected: "feature-2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitOutput(t, "checkout", tt.from)
+
+			if err := navigateStack(ctx, client, repo, tt.dir, tt.steps); err != nil {
+				t.Fatalf("navigateStack() error = %v", err)
+			}
+			if current := gitOutput(t, "branch", "--show-current"); current != tt.expected {
+				t.Errorf("current branch = %s, want %s", current, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNavigateStackPicksChild(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	client := &github.FakeClient{Open: stackPRs()}
+
+	gitOutput(t, "checkout", "feature-1")
+	if err := createBranch(ctx, repo, "feature-3", ""); err != nil {
+		t.Fatal(err)
+	}
+	gitOutput(t, "checkout", "feature-1")
+
+	var offered []string
+	original := pickChild
+	t.Cleanup(func() { pickChild = original })
+	pickChild = func(branch string, children []*github.TreeNode) (*github.TreeNode, error) {
+		for _, child := range children {
+			offered = append(offered, child.PR.HeadRefName)
+		}
+		return children[0], nil
+	}
+
+	if err := navigateStack(ctx, client, repo, directionUp, 1); err != nil {
+		t.Fatalf("navigateStack() error = %v", err)
+	}
+
+	if len(offered) != 2 {
+		t.Errorf("offered %v, want both children of feature-1", offered)
+	}
+	if current := gitOutput(t, "branch", "--show-current"); current != offered[0] {
+		t.Errorf("current branch = %s, want the picked %s", current, offered[0])
+	}
+}
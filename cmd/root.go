@@ -1,21 +1,29 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/charmbracelet/huh/spinner"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/cli/go-gh/v2/pkg/jq"
+	"github.com/cli/go-gh/v2/pkg/jsonpretty"
+	"github.com/cli/go-gh/v2/pkg/template"
+	"github.com/cli/go-gh/v2/pkg/term"
 	"github.com/spf13/cobra"
 	"github.com/vladimir-ananiev/gh-stack/pkg/git"
 	"github.com/vladimir-ananiev/gh-stack/pkg/github"
 )
 
 var (
-	errorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true)
-	warningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true)
-	hintStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("8")).Italic(true)
+	errorStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true)
+	warningStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true)
+	hintStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("8")).Italic(true)
+	completedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true)
 )
 
 var rootCmd = &cobra.Command{
@@ -24,131 +32,233 @@ var rootCmd = &cobra.Command{
 	Long: `A simple CLI tool for managing stacked Pull Request workflows on GitHub.
 	
 Shows dependency tree of open PRs and handles cascading rebases.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if prLimit <= 0 {
+			return fmt.Errorf("invalid --limit %d, expected a positive number", prLimit)
+		}
+		return nil
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return showStackStatus()
+		if (statusOpts.JQ != "" || statusOpts.Template != "") && !statusOpts.JSON {
+			return fmt.Errorf("cannot use --jq or --template without --json")
+		}
+		switch statusOpts.Format {
+		case "", github.FormatMermaid, github.FormatDot, github.FormatMarkdown:
+		default:
+			return fmt.Errorf("invalid --format %q, expected mermaid, dot or markdown", statusOpts.Format)
+		}
+		return showStackStatus(cmd.Context(), github.NewClient(), git.NewRepo(""), statusOpts)
 	},
 }
 
-func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
+type statusOptions struct {
+	JSON     bool
+	JQ       string
+	Template string
+	Format   string
+	Verbose  bool
 }
 
-var cascadeCmd = &cobra.Command{
-	Use:   "cascade",
-	Short: "Cascade rebase all branches in dependency order",
-	Long: `Checkout default branch (main/master), pull, then for each branch with PR targeting the default branch:
-	1. Checkout branch, rebase on target, push
-	2. For each dependent branch, checkout, rebase, push
-	
-Handles merged branches by dropping commits already in target.`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		return cascadeRebase()
-	},
-}
+var (
+	prLimit    int
+	statusOpts statusOptions
+)
 
 func init() {
-	rootCmd.AddCommand(cascadeCmd)
+	rootCmd.PersistentFlags().IntVar(&prLimit, "limit", 500, "Maximum number of open PRs to fetch")
+
+	rootCmd.Flags().BoolVar(&statusOpts.JSON, "json", false, "Output the stack tree as JSON")
+	rootCmd.Flags().StringVarP(&statusOpts.JQ, "jq", "q", "", "Filter JSON output using a jq expression")
+	rootCmd.Flags().StringVarP(&statusOpts.Template, "template", "t", "", "Format JSON output using a Go template; see \"gh help formatting\"")
+	rootCmd.Flags().StringVar(&statusOpts.Format, "format", "", "Export the stack tree as mermaid, dot or markdown")
+	rootCmd.Flags().BoolVarP(&statusOpts.Verbose, "verbose", "v", false, "List the failing checks of each PR")
+	rootCmd.MarkFlagsMutuallyExclusive("jq", "template")
+	rootCmd.MarkFlagsMutuallyExclusive("json", "format")
 }
 
-func showStackStatus() error {
-	ctx := context.Background()
+func Execute() {
+	if err := rootCmd.ExecuteContext(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
 
+func showStackStatus(ctx context.Context, client github.Client, repo git.Git, opts statusOptions) error {
 	// Get current branch
-	currentBranch, err := git.GetCurrentBranch(ctx)
+	currentBranch, err := repo.GetCurrentBranch(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get current branch: %w", err)
 	}
 
 	// Get all open PRs and build dependency tree
-	var prs []*github.PR
-	err = spinner.New().
-		Title("Fetching pull requests...").
-		Action(func() {
-			prs, err = github.GetOpenPRs(ctx)
-		}).
-		Run()
+	prs, err := fetchOpenPRs(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	closed, err := fetchClosedPRs(ctx, client)
 	if err != nil {
-		return fmt.Errorf("failed to get open PRs: %w", err)
+		return err
 	}
 
-	tree := github.BuildDependencyTree(prs)
-	github.PrintTree(tree, currentBranch)
+	tree, err := buildStackTree(ctx, repo, prs, closed)
+	if err != nil {
+		return err
+	}
+	if err := github.AnnotateRestackStatus(ctx, repo, tree); err != nil {
+		return err
+	}
+	if err := github.AnnotateDivergence(ctx, repo, tree); err != nil {
+		return err
+	}
+
+	if opts.JSON {
+		return exportStackJSON(os.Stdout, tree, currentBranch, opts)
+	}
+	if opts.Format != "" {
+		output, err := github.ExportTree(tree, currentBranch, opts.Format)
+		if err != nil {
+			return err
+		}
+		fmt.Print(output)
+		return nil
+	}
+
+	github.PrintTree(tree, currentBranch, opts.Verbose)
+
+	if github.NeedsRestack(tree) {
+		fmt.Printf("%s Branches marked ⟳ are behind their base, run 'gh stack cascade' to restack them\n",
+			hintStyle.Render("Hint:"))
+	}
 
 	return nil
 }
 
-func cascadeRebase() error {
-	ctx := context.Background()
+// exportStackJSON writes the tree as JSON, filtered with --jq or formatted with --template the way gh does
+func exportStackJSON(w io.Writer, tree []*github.TreeNode, currentBranch string, opts statusOptions) error {
+	data, err := json.Marshal(github.NewStackJSON(tree, currentBranch))
+	if err != nil {
+		return fmt.Errorf("failed to encode stack: %w", err)
+	}
+
+	terminal := term.FromEnv()
+	switch {
+	case opts.JQ != "":
+		return jq.EvaluateFormatted(bytes.NewReader(data), w, opts.JQ, "  ", terminal.IsColorEnabled())
+	case opts.Template != "":
+		width, _, _ := terminal.Size()
+		t := template.New(w, width, terminal.IsColorEnabled())
+		if err := t.Parse(opts.Template); err != nil {
+			return err
+		}
+		if err := t.Execute(bytes.NewReader(data)); err != nil {
+			return err
+		}
+		return t.Flush()
+	case terminal.IsTerminalOutput():
+		return jsonpretty.Format(w, bytes.NewReader(data), "  ", terminal.IsColorEnabled())
+	default:
+		_, err := w.Write(append(data, '\n'))
+		return err
+	}
+}
 
-	// Get current branch to restore later
-	currentBranch, err := git.GetCurrentBranch(ctx)
+// buildStackTree builds the dependency tree from open PRs merged with the parents recorded by 'gh stack create'.
+// Recorded parents that were merged or deleted are replaced by the PR's base, in the git config too.
+func buildStackTree(ctx context.Context, repo git.Git, prs, closed []*github.PR) ([]*github.TreeNode, error) {
+	parents, err := repo.GetStackParents(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get current branch: %w", err)
+		return nil, err
 	}
 
-	// Get all open PRs and build dependency tree
+	branchExists := func(branch string) bool {
+		_, err := repo.GetBranchSHA(ctx, branch)
+		return err == nil
+	}
+	for branch, parent := range github.StaleStackParents(prs, parents, closed, branchExists) {
+		if err := repo.SetStackParent(ctx, branch, parent); err != nil {
+			return nil, err
+		}
+		parents[branch] = parent
+	}
+
+	return github.BuildDependencyTree(github.MergeStackParents(prs, parents)), nil
+}
+
+// fetchOpenPRs fetches open PRs, warning when the --limit cap truncates the list
+func fetchOpenPRs(ctx context.Context, client github.Client) ([]*github.PR, error) {
 	var prs []*github.PR
-	err = spinner.New().
+	var truncated bool
+	// Progress goes to stderr so it doesn't mix with --json output
+	err := spinner.New().
 		Title("Fetching pull requests...").
-		Action(func() {
-			prs, err = github.GetOpenPRs(ctx)
+		Output(os.Stderr).
+		ActionWithErr(func(context.Context) error {
+			var err error
+			prs, truncated, err = client.GetOpenPRs(ctx, prLimit)
+			return err
 		}).
 		Run()
 	if err != nil {
-		return fmt.Errorf("failed to get open PRs: %w", err)
+		return nil, fmt.Errorf("failed to get open PRs: %w", err)
 	}
 
-	tree := github.BuildDependencyTree(prs)
-
-	// Find the tree containing the current branch
-	currentTree := github.FindCurrentBranchTree(tree, currentBranch)
-	if currentTree == nil {
-		fmt.Printf("%s %s has no open PR or is not part of a PR stack\n\n", 
-			errorStyle.Render("✗ Error:"), 
-			warningStyle.Render(currentBranch))
-		fmt.Printf("%s Switch to a branch that has an open PR to use cascade\n", 
-			hintStyle.Render("Hint:"))
-		return nil // Return nil to prevent cobra from showing the error again
+	if truncated {
+		fmt.Fprintf(os.Stderr, "%s only the first %d open PRs were fetched, stacks may be incomplete\n",
+			warningStyle.Render("⚠ Warning:"), prLimit)
+		fmt.Fprintf(os.Stderr, "%s Raise the cap with --limit\n\n", hintStyle.Render("Hint:"))
 	}
 
-	// Get the base branch for this tree
-	baseBranch := currentTree.PR.BaseRefName
+	return prs, nil
+}
 
-	// Checkout base branch and pull
-	err = spinner.New().
-		Title(fmt.Sprintf("Updating %s...", baseBranch)).
-		Action(func() {
-			err = git.CheckoutAndPull(ctx, baseBranch)
+// fetchClosedPRs fetches the most recently merged or closed PRs
+func fetchClosedPRs(ctx context.Context, client github.Client) ([]*github.PR, error) {
+	var closed []*github.PR
+	// Progress goes to stderr so it doesn't mix with --json output
+	err := spinner.New().
+		Title("Fetching merged pull requests...").
+		Output(os.Stderr).
+		ActionWithErr(func(context.Context) error {
+			var err error
+			closed, err = client.GetClosedPRs(ctx)
+			return err
 		}).
 		Run()
 	if err != nil {
-		return fmt.Errorf("failed to update %s: %w", baseBranch, err)
+		return nil, fmt.Errorf("failed to get closed PRs: %w", err)
 	}
+	return closed, nil
+}
 
-	// Process only the current tree in dependency order
-	err = spinner.New().
-		Title(fmt.Sprintf("Rebasing %s → %s...", currentTree.PR.HeadRefName, currentTree.PR.BaseRefName)).
-		Action(func() {
-			err = github.ProcessSingleTreeRebase(ctx, currentTree)
-		}).
-		Run()
-	if err != nil {
-		return err
+// updateStackNavigation refreshes the stack navigation section in the description of every PR in the stack
+func updateStackNavigation(ctx context.Context, client github.Client, root *github.TreeNode) error {
+	var nodes []*github.TreeNode
+	var collect func(node *github.TreeNode)
+	collect = func(node *github.TreeNode) {
+		if !node.PR.IsLocal {
+			nodes = append(nodes, node)
+		}
+		for _, child := range node.Children {
+			collect(child)
+		}
 	}
+	collect(root)
 
-	// Restore original branch
-	err = spinner.New().
-		Title(fmt.Sprintf("Returning to %s...", currentBranch)).
-		Action(func() {
-			err = git.CheckoutBranch(ctx, currentBranch)
+	return spinner.New().
+		Title("Updating stack navigation in PR descriptions...").
+		ActionWithErr(func(context.Context) error {
+			for _, node := range nodes {
+				body := github.InjectStackNavigation(node.PR.Body, github.FormatStackNavigation(root, node.PR))
+				if body == node.PR.Body {
+					continue
+				}
+				if err := client.UpdatePRBody(ctx, node.PR.Number, body); err != nil {
+					return err
+				}
+				node.PR.Body = body
+			}
+			return nil
 		}).
 		Run()
-	if err != nil {
-		return fmt.Errorf("failed to restore branch %s: %w", currentBranch, err)
-	}
-
-	return nil
 }
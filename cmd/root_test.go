@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/vladimir-ananiev/gh-stack/pkg/github"
+)
+
+func TestExportStackJSON(t *testing.T) {
+	tree := github.BuildDependencyTree(stackPRs())
+
+	tests := []struct {
+		name     string
+		opts     statusOptions
+		expected string
+	}{
+		{
+			name:     "jq",
+			opts:     statusOptions{JSON: true, JQ: ".stacks[].children[] | select(.isCurrent) | .number"},
+			expected: "2\n",
+		},
+		{
+			name:     "template",
+			opts:     statusOptions{JSON: true, Template: "{{range .stacks}}{{.headRefName}} → {{.parent}}{{end}}"},
+			expected: "feature-1 → main",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := exportStackJSON(&out, tree, "feature-2", tt.opts); err != nil {
+				t.Fatalf("exportStackJSON() error = %v", err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("exportStackJSON() = %q, want %q", out.String(), tt.expected)
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	if err := exportStackJSON(&out, tree, "feature-2", statusOptions{JSON: true}); err != nil {
+		t.Fatalf("exportStackJSON() error = %v", err)
+	}
+	var decoded github.StackJSON
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || decoded.CurrentBranch != "feature-2" {
+		t.Errorf("exportStackJSON() wrote %q, %v", out.String(), err)
+	}
+}
+
+func TestLimitMustBePositive(t *testing.T) {
+	original := prLimit
+	t.Cleanup(func() { prLimit = original })
+
+	for _, limit := range []int{0, -1} {
+		prLimit = limit
+		if err := rootCmd.PersistentPreRunE(rootCmd, nil); err == nil {
+			t.Errorf("--limit %d was accepted", limit)
+		}
+	}
+
+	prLimit = 1
+	if err := rootCmd.PersistentPreRunE(rootCmd, nil); err != nil {
+		t.Errorf("--limit 1 was rejected: %v", err)
+	}
+}
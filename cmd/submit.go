@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/huh/spinner"
+	"github.com/spf13/cobra"
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+	"github.com/vladimir-ananiev/gh-stack/pkg/github"
+)
+
+type submitOptions struct {
+	Draft     bool
+	Reviewers []string
+	Force     bool
+}
+
+var submitOpts submitOptions
+
+var submitCmd = &cobra.Command{
+	Use:   "submit",
+	Short: "Push the current stack and open or update its PRs",
+	Long: `Walk the stack containing the current branch and, for each branch:
+	1. Push it to origin, unless it is up to date or has no local branch
+	2. Open a PR against its parent if it doesn't have one, titled after its first commit
+	3. Retarget its PR if the base doesn't match the parent recorded with 'gh stack create'
+
+Every PR description then gets a navigation section listing the whole stack.
+
+Branches that are behind or have diverged from their remote, for example because a teammate
+pushed to them, would lose those commits, so nothing is submitted until their changes are
+pulled or --force is given.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return submitStack(cmd.Context(), github.NewClient(), git.NewRepo(""), submitOpts)
+	},
+}
+
+func init() {
+	submitCmd.Flags().BoolVar(&submitOpts.Draft, "draft", false, "Open new PRs as drafts")
+	submitCmd.Flags().StringSliceVar(&submitOpts.Reviewers, "reviewer", nil, "Request reviews on new PRs from these users")
+	submitCmd.Flags().BoolVar(&submitOpts.Force, "force", false, "Push even if branches have diverged from their remote")
+
+	rootCmd.AddCommand(submitCmd)
+}
+
+func submitStack(ctx context.Context, client github.Client, repo git.Git, opts submitOptions) error {
+	state, err := github.LoadCascadeState(ctx, repo)
+	if err != nil {
+		return err
+	}
+	if state != nil {
+		fmt.Printf("%s a cascade is already in progress\n\n", errorStyle.Render("✗ Error:"))
+		fmt.Printf("%s Use 'gh stack cascade --continue', '--skip' or '--abort'\n",
+			hintStyle.Render("Hint:"))
+		return nil
+	}
+
+	currentBranch, err := repo.GetCurrentBranch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	prs, err := fetchOpenPRs(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	closed, err := fetchClosedPRs(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	tree, err := buildStackTree(ctx, repo, prs, closed)
+	if err != nil {
+		return err
+	}
+	root := github.FindCurrentBranchTree(tree, currentBranch)
+	if root == nil {
+		fmt.Printf("%s current branch %s is not part of any stack\n\n", errorStyle.Render("✗ Error:"), currentBranch)
+		fmt.Printf("%s Start a stack with 'gh stack create <branch>'\n", hintStyle.Render("Hint:"))
+		return nil
+	}
+
+	// Force-pushing a branch someone else pushed to would silently drop their commits
+	if err := github.AnnotateDivergence(ctx, repo, []*github.TreeNode{root}); err != nil {
+		return err
+	}
+	if diverged := github.FindDiverged([]*github.TreeNode{root}); len(diverged) > 0 && !opts.Force {
+		printDivergence(diverged, errorStyle.Render("✗ Error:"))
+		fmt.Printf("%s Pull the remote changes into these branches first, or use --force to overwrite them\n",
+			hintStyle.Render("Hint:"))
+		return nil
+	}
+
+	if err := submitNode(ctx, client, repo, root, opts); err != nil {
+		return err
+	}
+
+	return updateStackNavigation(ctx, client, root)
+}
+
+// submitNode pushes the branch of node and makes sure its PR exists and targets its parent, then recurses into its children
+func submitNode(ctx context.Context, client github.Client, repo git.Git, node *github.TreeNode, opts submitOptions) error {
+	branch, base := node.PR.HeadRefName, node.PR.BaseRefName
+
+	// Someone else's PR in the stack may not be checked out, there is nothing to push for it
+	local, err := repo.GetBranchSHA(ctx, branch)
+	if err != nil {
+		fmt.Printf("%s %s has no local branch, skipping it\n", hintStyle.Render("Hint:"), branch)
+		return submitChildren(ctx, client, repo, node, opts)
+	}
+
+	if remote := github.RemoteSHA(ctx, repo, node.PR); remote == local {
+		fmt.Printf("%s %s\n", completedStyle.Render("✓ Up to date"), branch)
+	} else {
+		err = spinner.New().
+			Title(fmt.Sprintf("Pushing %s...", branch)).
+			ActionWithErr(func(context.Context) error {
+				return repo.PublishBranch(ctx, branch)
+			}).
+			Run()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s %s\n", completedStyle.Render("✓ Pushed"), branch)
+	}
+
+	if node.PR.IsLocal {
+		title, body, err := repo.FirstCommitMessage(ctx, base, branch)
+		if err != nil {
+			title, body = branch, ""
+		}
+
+		var pr *github.PR
+		err = spinner.New().
+			Title(fmt.Sprintf("Opening PR for %s → %s...", branch, base)).
+			ActionWithErr(func(context.Context) error {
+				var err error
+				pr, err = client.CreatePR(ctx, github.CreatePROptions{
+					Head:      branch,
+					Base:      base,
+					Title:     title,
+					Body:      body,
+					Draft:     opts.Draft,
+					Reviewers: opts.Reviewers,
+				})
+				return err
+			}).
+			Run()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s #%d %s → %s\n", completedStyle.Render("✓ Opened"), pr.Number, branch, base)
+		node.PR = pr
+	} else if pr := node.PR; pr.MismatchedBase != "" {
+		err = spinner.New().
+			Title(fmt.Sprintf("Retargeting #%d → %s...", pr.Number, base)).
+			ActionWithErr(func(context.Context) error {
+				return client.RetargetPR(ctx, pr.Number, base)
+			}).
+			Run()
+		if err != nil {
+			return fmt.Errorf("failed to retarget #%d: %w", pr.Number, err)
+		}
+		fmt.Printf("%s #%d %s → %s\n", completedStyle.Render("✓ Retargeted"), pr.Number, pr.MismatchedBase, base)
+		pr.MismatchedBase = ""
+	}
+
+	return submitChildren(ctx, client, repo, node, opts)
+}
+
+// submitChildren submits each child of node in turn
+func submitChildren(ctx context.Context, client github.Client, repo git.Git, node *github.TreeNode, opts submitOptions) error {
+	for _, child := range node.Children {
+		if err := submitNode(ctx, client, repo, child, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+	"github.com/vladimir-ananiev/gh-stack/pkg/github"
+)
+
+func TestSubmitStack(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+
+	// feature-3 only exists locally, and #2 points at the wrong base
+	if err := createBranch(ctx, repo, "feature-3", ""); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, "feature-3.txt", "feature-3\n")
+	gitOutput(t, "add", "feature-3.txt")
+	gitOutput(t, "commit", "-m", "Feature 3\n\nAdds feature 3.")
+	gitOutput(t, "config", "branch.feature-2.stackParent", "feature-1")
+
+	prs := stackPRs()
+	prs[1].BaseRefName = "main"
+	client := &github.FakeClient{Open: prs}
+
+	opts := submitOptions{Draft: true, Reviewers: []string{"octocat"}}
+	if err := submitStack(ctx, client, repo, opts); err != nil {
+		t.Fatalf("submitStack() error = %v", err)
+	}
+
+	expected := []github.CreatePROptions{{
+		Head:      "feature-3",
+		Base:      "feature-2",
+		Title:     "Feature 3",
+		Body:      "Adds feature 3.",
+		Draft:     true,
+		Reviewers: []string{"octocat"},
+	}}
+	if !reflect.DeepEqual(client.Created, expected) {
+		t.Errorf("created PRs = %+v, want %+v", client.Created, expected)
+	}
+	if base := client.Open[1].BaseRefName; base != "feature-1" {
+		t.Errorf("#2 base = %s, want feature-1", base)
+	}
+
+	if body := client.Open[1].Body; !strings.Contains(body, "**#2 Second** 👈") {
+		t.Errorf("#2 description has no stack navigation: %q", body)
+	}
+	if body := client.Open[2].Body; !strings.HasPrefix(body, "Adds feature 3.\n\n<!-- gh-stack:start -->") {
+		t.Errorf("#3 description = %q, want commit body followed by stack navigation", body)
+	}
+
+	// Submitting again leaves descriptions alone
+	before := client.Open[2].Body
+	if err := submitStack(ctx, client, repo, opts); err != nil {
+		t.Fatalf("submitStack() error = %v", err)
+	}
+	if after := client.Open[2].Body; after != before {
+		t.Errorf("second submit changed description to %q", after)
+	}
+	if len(client.Created) != 1 {
+		t.Errorf("second submit opened %d more PRs", len(client.Created)-1)
+	}
+
+	remote := filepath.Join(filepath.Dir(mustGetwd(t)), "remote.git")
+	if got, want := runGit(t, remote, "rev-parse", "feature-3"), gitOutput(t, "rev-parse", "feature-3"); got != want {
+		t.Errorf("remote feature-3 = %s, want %s", got, want)
+	}
+}
+
+func TestSubmitStackRefusesDivergedBranches(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	remote := filepath.Join(filepath.Dir(mustGetwd(t)), "remote.git")
+
+	// A teammate pushes to feature-1, and we fetch it without pulling
+	other := filepath.Join(filepath.Dir(mustGetwd(t)), "other")
+	runGit(t, other, "fetch", "origin")
+	runGit(t, other, "checkout", "feature-1")
+	writeFileIn(t, other, "teammate.txt", "teammate\n")
+	runGit(t, other, "add", ".")
+	runGit(t, other, "commit", "-m", "Teammate change")
+	runGit(t, other, "push", "origin", "feature-1")
+	theirs := runGit(t, other, "rev-parse", "HEAD")
+	gitOutput(t, "fetch")
+
+	client := &github.FakeClient{Open: stackPRs()}
+	if err := submitStack(ctx, client, repo, submitOptions{}); err != nil {
+		t.Fatalf("submitStack() error = %v", err)
+	}
+	if got := runGit(t, remote, "rev-parse", "feature-1"); got != theirs {
+		t.Errorf("remote feature-1 was rewound to %s", got)
+	}
+
+	// --force overwrites it
+	if err := submitStack(ctx, client, repo, submitOptions{Force: true}); err != nil {
+		t.Fatalf("submitStack(--force) error = %v", err)
+	}
+	if got, want := runGit(t, remote, "rev-parse", "feature-1"), gitOutput(t, "rev-parse", "feature-1"); got != want {
+		t.Errorf("remote feature-1 = %s, want %s", got, want)
+	}
+}
+
+func TestSubmitStackSkipsUnchangedAndMissingBranches(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+
+	// Only pushes to feature-2 are accepted
+	hook := filepath.Join(filepath.Dir(mustGetwd(t)), "remote.git", "hooks", "pre-receive")
+	script := "#!/bin/sh\nwhile read old new ref; do [ \"$ref\" = refs/heads/feature-2 ] || exit 1; done\nexit 0\n"
+	if err := os.WriteFile(hook, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// feature-1 is up to date, and a teammate's PR on top of feature-2 isn't checked out
+	commitFile(t, "feature-2.txt", "more\n", "More feature 2")
+	prs := append(stackPRs(), &github.PR{Number: 3, Title: "Third", HeadRefName: "feature-3", BaseRefName: "feature-2"})
+	client := &github.FakeClient{Open: prs}
+	if err := submitStack(ctx, client, repo, submitOptions{}); err != nil {
+		t.Fatalf("submitStack() error = %v", err)
+	}
+
+	remote := filepath.Join(filepath.Dir(mustGetwd(t)), "remote.git")
+	if got, want := runGit(t, remote, "rev-parse", "feature-2"), gitOutput(t, "rev-parse", "feature-2"); got != want {
+		t.Errorf("remote feature-2 = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,299 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/huh/spinner"
+	"github.com/spf13/cobra"
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+	"github.com/vladimir-ananiev/gh-stack/pkg/github"
+)
+
+type syncOptions struct {
+	Force bool
+}
+
+var syncOpts syncOptions
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Retarget and restack PRs whose parent was merged",
+	Long: `Find open PRs whose base branch belongs to a merged PR, then:
+	1. Retarget each of them onto the merged PR's base
+	2. Rebase them, replaying only their own commits, and restack their dependents
+	3. Offer to delete the merged local branches
+
+PRs based on a PR that was closed without merging are only listed, since whether its
+commits still belong in the stack is up to you.
+
+Nothing is retargeted while uncommitted changes would block the rebase, or while a
+branch to restack differs from its remote, unless --force is given.
+
+Conflicts are resolved the same way as in cascade, with 'gh stack cascade --continue'.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return syncStack(cmd.Context(), github.NewClient(), git.NewRepo(""), syncOpts)
+	},
+}
+
+func init() {
+	syncCmd.Flags().BoolVar(&syncOpts.Force, "force", false, "Restack even if branches have diverged from their remote")
+
+	rootCmd.AddCommand(syncCmd)
+}
+
+func syncStack(ctx context.Context, client github.Client, repo git.Git, opts syncOptions) error {
+	state, err := github.LoadCascadeState(ctx, repo)
+	if err != nil {
+		return err
+	}
+	if state != nil {
+		fmt.Printf("%s a cascade is already in progress\n\n", errorStyle.Render("✗ Error:"))
+		fmt.Printf("%s Use 'gh stack cascade --continue', '--skip' or '--abort'\n",
+			hintStyle.Render("Hint:"))
+		return nil
+	}
+
+	currentBranch, err := repo.GetCurrentBranch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	open, err := fetchOpenPRs(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	closed, err := fetchClosedPRs(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	// Read before building any tree, which replaces merged parents
+	parents, err := repo.GetStackParents(ctx)
+	if err != nil {
+		return err
+	}
+
+	orphans, unmerged := github.FindOrphanedPRs(open, closed, parents)
+	if len(orphans) == 0 && len(unmerged) == 0 {
+		fmt.Println("No PRs based on merged or closed branches")
+		return nil
+	}
+
+	// A closed PR's commits may still be wanted, so its children are left for the user to sort out
+	printUnmergedParents(unmerged)
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	// Check the restack can go through before retargeting anything
+	tree, err := buildStackTree(ctx, repo, open, closed)
+	if err != nil {
+		return err
+	}
+	var roots []*github.TreeNode
+	for _, orphan := range orphans {
+		if node := github.FindBranchNode(tree, orphan.PR.HeadRefName); node != nil {
+			roots = append(roots, node)
+		}
+	}
+	if ok, err := checkRestack(ctx, repo, roots, opts.Force); err != nil || !ok {
+		return err
+	}
+
+	var merged []*github.PR
+	for _, orphan := range orphans {
+		merged = append(merged, orphan.Parent)
+	}
+	if err := restackOrphans(ctx, client, repo, open, orphans, currentBranch, merged); err != nil {
+		return err
+	}
+
+	// A conflict leaves the cascade in progress; merged branches are offered once it completes
+	if state, err := github.LoadCascadeState(ctx, repo); err != nil || state != nil {
+		return err
+	}
+	return deleteMergedBranches(ctx, repo, mergedBranches(merged), currentBranch)
+}
+
+// printUnmergedParents lists the PRs based on a PR that was closed without merging, with what to do about them
+func printUnmergedParents(unmerged []*github.Orphan) {
+	if len(unmerged) == 0 {
+		return
+	}
+
+	fmt.Printf("%s these PRs are based on a PR that was closed without merging:\n", warningStyle.Render("⚠ Warning:"))
+	for _, orphan := range unmerged {
+		fmt.Printf("  #%d %s → #%d %s\n", orphan.PR.Number, warningStyle.Render(orphan.PR.HeadRefName),
+			orphan.Parent.Number, orphan.Parent.HeadRefName)
+	}
+	fmt.Printf("\n%s Reopen the closed PR, or retarget its children with 'gh pr edit <number> --base <branch>'\n\n",
+		hintStyle.Render("Hint:"))
+}
+
+// checkRestack reports whether the branches of the trees can be restacked: none of them may differ from
+// its remote, unless force is set, and no uncommitted changes may block checking them out.
+// Otherwise it prints what is in the way.
+func checkRestack(ctx context.Context, repo git.Git, roots []*github.TreeNode, force bool) (bool, error) {
+	if len(roots) == 0 {
+		return true, nil
+	}
+
+	// Force-pushing a branch someone else pushed to would silently drop their commits
+	if err := github.AnnotateDivergence(ctx, repo, roots); err != nil {
+		return false, err
+	}
+	if diverged := github.FindDiverged(roots); len(diverged) > 0 && !force {
+		printDivergence(diverged, errorStyle.Render("✗ Error:"))
+		fmt.Printf("%s Pull the remote changes into these branches first, or use --force to overwrite them\n",
+			hintStyle.Render("Hint:"))
+		return false, nil
+	}
+
+	modified, err := repo.GetModifiedFiles(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(modified) > 0 {
+		printModifiedFiles(modified, errorStyle.Render("✗ Error:"))
+		fmt.Printf("%s Commit or stash them first\n", hintStyle.Render("Hint:"))
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// restackOrphans retargets each orphan onto its new base, then rebases it and its dependents
+// onto the updated base. A conflict leaves the cascade in progress, remembering the merged PRs' branches
+// to offer for deletion once it completes.
+func restackOrphans(ctx context.Context, client github.Client, repo git.Git, open []*github.PR, orphans []*github.Orphan, currentBranch string, merged []*github.PR) error {
+	parents, err := repo.GetStackParents(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Retarget each orphan onto the base its parent was merged into
+	for _, orphan := range orphans {
+		// GitHub retargets a PR itself when its base branch is deleted
+		if !orphan.PR.IsLocal && orphan.PR.BaseRefName != orphan.NewBase {
+			err = spinner.New().
+				Title(fmt.Sprintf("Retargeting #%d → %s...", orphan.PR.Number, orphan.NewBase)).
+				ActionWithErr(func(context.Context) error {
+					return client.RetargetPR(ctx, orphan.PR.Number, orphan.NewBase)
+				}).
+				Run()
+			if err != nil {
+				return err
+			}
+		}
+		orphan.PR.BaseRefName = orphan.NewBase
+
+		// Keep the local stack metadata in line, so the merged parent doesn't hold on to the branch
+		if _, ok := parents[orphan.PR.HeadRefName]; ok {
+			parent := git.StackParent{Branch: orphan.NewBase, Tip: orphan.Parent.HeadRefOid}
+			if err := repo.SetStackParent(ctx, orphan.PR.HeadRefName, parent); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Update every new base before rebasing onto it
+	updated := make(map[string]bool)
+	for _, orphan := range orphans {
+		if updated[orphan.NewBase] {
+			continue
+		}
+		err = spinner.New().
+			Title(fmt.Sprintf("Updating %s...", orphan.NewBase)).
+			ActionWithErr(func(context.Context) error {
+				return repo.CheckoutAndPull(ctx, orphan.NewBase)
+			}).
+			Run()
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", orphan.NewBase, err)
+		}
+		updated[orphan.NewBase] = true
+	}
+
+	// Restack each orphan and its dependents, replaying only commits after the merged parent's tip
+	tree, err := buildStackTree(ctx, repo, open, merged)
+	if err != nil {
+		return err
+	}
+	var roots []*github.TreeNode
+	for _, orphan := range orphans {
+		roots = append(roots, github.FindBranchNode(tree, orphan.PR.HeadRefName))
+	}
+
+	state, err := github.NewCascadeState(ctx, repo, currentBranch, roots...)
+	if err != nil {
+		return fmt.Errorf("failed to plan restack: %w", err)
+	}
+	for _, orphan := range orphans {
+		if orphan.Parent.HeadRefOid != "" {
+			state.Step(orphan.PR.HeadRefName).OldBaseSHA = orphan.Parent.HeadRefOid
+		}
+	}
+	state.Merged = mergedBranches(merged)
+	if err := state.Save(ctx, repo); err != nil {
+		return err
+	}
+
+	return runCascade(ctx, repo, state)
+}
+
+// mergedBranches returns the distinct head branches of the PRs that were merged
+func mergedBranches(prs []*github.PR) []github.MergedBranch {
+	var branches []github.MergedBranch
+	for _, pr := range prs {
+		if pr.State != "MERGED" || slices.ContainsFunc(branches, func(b github.MergedBranch) bool { return b.Branch == pr.HeadRefName }) {
+			continue
+		}
+		branches = append(branches, github.MergedBranch{Branch: pr.HeadRefName, Number: pr.Number})
+	}
+	return branches
+}
+
+// deleteMergedBranches offers to delete the local branch of each merged PR
+func deleteMergedBranches(ctx context.Context, repo git.Git, merged []github.MergedBranch, currentBranch string) error {
+	for _, mb := range merged {
+		branch := mb.Branch
+
+		if _, err := repo.GetBranchSHA(ctx, branch); err != nil {
+			continue // No local branch to delete
+		}
+		if branch == currentBranch {
+			fmt.Printf("%s %s is checked out, switch away to delete it\n",
+				hintStyle.Render("Hint:"), branch)
+			continue
+		}
+
+		confirmed, err := confirmDelete(mb)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			continue
+		}
+
+		if err := repo.DeleteBranch(ctx, branch); err != nil {
+			return err
+		}
+		fmt.Printf("%s %s\n", completedStyle.Render("✓ Deleted"), branch)
+	}
+
+	return nil
+}
+
+// confirmDelete asks whether to delete the local branch of a merged PR; replaced in tests
+var confirmDelete = func(merged github.MergedBranch) (bool, error) {
+	var confirmed bool
+	err := huh.NewConfirm().
+		Title(fmt.Sprintf("Delete merged local branch %s (#%d)?", merged.Branch, merged.Number)).
+		Value(&confirmed).
+		Run()
+	return confirmed, err
+}
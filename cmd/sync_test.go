@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+	"github.com/vladimir-ananiev/gh-stack/pkg/github"
+)
+
+func TestSyncStackAfterSquashMerge(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+
+	// Give feature-1 a second commit so its squash doesn't match any single commit
+	gitOutput(t, "checkout", "feature-1")
+	commitFile(t, "shared.txt", "feature-1 v2\n", "Feature 1 follow-up")
+	gitOutput(t, "push")
+	gitOutput(t, "checkout", "feature-2")
+	gitOutput(t, "rebase", "feature-1")
+	gitOutput(t, "push", "--force")
+	feature1 := gitOutput(t, "rev-parse", "feature-1")
+
+	// Squash-merge feature-1 into main on the remote
+	other := filepath.Join(filepath.Dir(mustGetwd(t)), "other")
+	runGit(t, other, "fetch", "origin")
+	runGit(t, other, "merge", "--squash", "origin/feature-1")
+	runGit(t, other, "commit", "-m", "Feature 1 (#1)")
+	runGit(t, other, "push", "origin", "main")
+	gitOutput(t, "branch", "-D", "feature-1")
+
+	client := &github.FakeClient{
+		Open: []*github.PR{
+			{Number: 2, Title: "Second", HeadRefName: "feature-2", BaseRefName: "feature-1"},
+		},
+		Closed: []*github.PR{
+			{Number: 1, Title: "First", HeadRefName: "feature-1", BaseRefName: "main", HeadRefOid: feature1, State: "MERGED"},
+		},
+	}
+	if err := syncStack(ctx, client, repo, syncOptions{}); err != nil {
+		t.Fatalf("syncStack() error = %v", err)
+	}
+
+	if base := client.Open[0].BaseRefName; base != "main" {
+		t.Errorf("#2 base = %s, want main", base)
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Fatalf("restack stopped with a conflict")
+	}
+	if !isAncestor(t, "main", "origin/feature-2") {
+		t.Errorf("feature-2 was not rebased onto main")
+	}
+	if count := gitOutput(t, "rev-list", "--count", "main..feature-2"); count != "1" {
+		t.Errorf("feature-2 has %s commits on top of main, want 1", count)
+	}
+}
+
+func TestSyncStackLeavesChildrenOfClosedPR(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	feature2 := gitOutput(t, "rev-parse", "feature-2")
+
+	client := &github.FakeClient{
+		Open: []*github.PR{
+			{Number: 2, Title: "Second", HeadRefName: "feature-2", BaseRefName: "feature-1"},
+		},
+		Closed: []*github.PR{
+			{Number: 1, Title: "First", HeadRefName: "feature-1", BaseRefName: "main", State: "CLOSED"},
+		},
+	}
+	if err := syncStack(ctx, client, repo, syncOptions{}); err != nil {
+		t.Fatalf("syncStack() error = %v", err)
+	}
+
+	if base := client.Open[0].BaseRefName; base != "feature-1" {
+		t.Errorf("#2 base = %s, want feature-1", base)
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Errorf("a restack was started")
+	}
+	if after := gitOutput(t, "rev-parse", "feature-2"); after != feature2 {
+		t.Errorf("feature-2 moved to %s", after)
+	}
+}
+
+func TestSyncStackOffersDeletionAfterContinue(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	feature1 := gitOutput(t, "rev-parse", "feature-1")
+
+	// Squash-merge feature-1 into main, along with a change that conflicts with feature-2
+	other := filepath.Join(filepath.Dir(mustGetwd(t)), "other")
+	runGit(t, other, "fetch", "origin")
+	runGit(t, other, "merge", "--squash", "origin/feature-1")
+	runGit(t, other, "commit", "-m", "Feature 1 (#1)")
+	writeFileIn(t, other, "feature-2.txt", "main\n")
+	runGit(t, other, "add", ".")
+	runGit(t, other, "commit", "-m", "Main conflicts with feature-2")
+	runGit(t, other, "push", "origin", "main")
+
+	var offered []string
+	original := confirmDelete
+	confirmDelete = func(merged github.MergedBranch) (bool, error) {
+		offered = append(offered, merged.Branch)
+		return true, nil
+	}
+	t.Cleanup(func() { confirmDelete = original })
+
+	client := &github.FakeClient{
+		Open: []*github.PR{
+			{Number: 2, Title: "Second", HeadRefName: "feature-2", BaseRefName: "feature-1"},
+		},
+		Closed: []*github.PR{
+			{Number: 1, Title: "First", HeadRefName: "feature-1", BaseRefName: "main", HeadRefOid: feature1, State: "MERGED"},
+		},
+	}
+	if err := syncStack(ctx, client, repo, syncOptions{}); err != nil {
+		t.Fatalf("syncStack() error = %v", err)
+	}
+	if state, _ := github.LoadCascadeState(ctx, repo); state == nil {
+		t.Fatalf("restack did not stop at the conflict")
+	}
+	if len(offered) != 0 {
+		t.Errorf("deletion offered before the restack completed: %v", offered)
+	}
+
+	writeFile(t, "feature-2.txt", "resolved\n")
+	gitOutput(t, "add", "feature-2.txt")
+	if err := cascadeRebase(ctx, client, repo, cascadeOptions{Continue: true}); err != nil {
+		t.Fatalf("cascadeRebase(--continue) error = %v", err)
+	}
+
+	if len(offered) != 1 || offered[0] != "feature-1" {
+		t.Errorf("offered to delete %v, want [feature-1]", offered)
+	}
+	if _, err := repo.GetBranchSHA(ctx, "feature-1"); err == nil {
+		t.Errorf("feature-1 was not deleted")
+	}
+}
+
+func TestSyncStackAfterRecordedParentMerged(t *testing.T) {
+	ctx := context.Background()
+	setupStackRepo(t)
+	repo := git.NewRepo("")
+	gitOutput(t, "config", "branch.feature-2.stackParent", "feature-1")
+	gitOutput(t, "config", "branch.feature-2.stackParentTip", gitOutput(t, "rev-parse", "feature-1"))
+
+	// GitHub already retargeted #2 to main, and the merged feature-1 is still around locally
+	feature1 := squashMergeOnRemote(t, "feature-1")
+	gitOutput(t, "branch", "feature-1", feature1)
+	original := confirmDelete
+	confirmDelete = func(github.MergedBranch) (bool, error) { return false, nil }
+	t.Cleanup(func() { confirmDelete = original })
+
+	client := &github.FakeClient{
+		Open: []*github.PR{
+			{Number: 2, Title: "Second", HeadRefName: "feature-2", BaseRefName: "main"},
+		},
+		Closed: []*github.PR{
+			{Number: 1, Title: "First", HeadRefName: "feature-1", BaseRefName: "main", HeadRefOid: feature1, State: "MERGED"},
+		},
+	}
+	if err := syncStack(ctx, client, repo, syncOptions{}); err != nil {
+		t.Fatalf("syncStack() error = %v", err)
+	}
+
+	if state, _ := github.LoadCascadeState(ctx, repo); state != nil {
+		t.Fatalf("restack stopped with a conflict")
+	}
+	if parent := gitOutput(t, "config", "branch.feature-2.stackParent"); parent != "main" {
+		t.Errorf("feature-2 stack parent = %s, want main", parent)
+	}
+	if log := gitOutput(t, "log", "--format=%s", "main..origin/feature-2"); log != "Feature 2" {
+		t.Errorf("feature-2 has %q on top of main, want only Feature 2", log)
+	}
+}
+
+func TestSyncStackChecksRestackFirst(t *testing.T) {
+	tests := []struct {
+		name         string
+		setup        func(t *testing.T) string
+		opts         syncOptions
+		expectedBase string
+	}{
+		{
+			name: "diverged orphan",
+			setup: func(t *testing.T) string {
+				return pushTeammateChange(t, "feature-2")
+			},
+			expectedBase: "feature-1",
+		},
+		{
+			name: "diverged orphan with --force",
+			setup: func(t *testing.T) string {
+				return pushTeammateChange(t, "feature-2")
+			},
+			opts:         syncOptions{Force: true},
+			expectedBase: "main",
+		},
+		{
+			name: "uncommitted changes",
+			setup: func(t *testing.T) string {
+				writeFile(t, "feature-2.txt", "work in progress\n")
+				return ""
+			},
+			expectedBase: "feature-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			setupStackRepo(t)
+			repo := git.NewRepo("")
+			feature1 := squashMergeOnRemote(t, "feature-1")
+			feature2 := tt.setup(t)
+
+			client := &github.FakeClient{
+				Open: []*github.PR{
+					{Number: 2, Title: "Second", HeadRefName: "feature-2", BaseRefName: "feature-1", HeadRefOid: feature2},
+				},
+				Closed: []*github.PR{
+					{Number: 1, Title: "First", HeadRefName: "feature-1", BaseRefName: "main", HeadRefOid: feature1, State: "MERGED"},
+				},
+			}
+			if err := syncStack(ctx, client, repo, tt.opts); err != nil {
+				t.Fatalf("syncStack() error = %v", err)
+			}
+
+			if base := client.Open[0].BaseRefName; base != tt.expectedBase {
+				t.Errorf("#2 base = %s, want %s", base, tt.expectedBase)
+			}
+		})
+	}
+}
@@ -2,9 +2,11 @@ package git
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
@@ -15,42 +17,87 @@ type Commit struct {
 	Message string
 }
 
-func getRepo() (*git.Repository, error) {
-	pwd, err := os.Getwd()
+// GetCurrentBranch returns the name of the currently checked out branch
+func (r *Repo) GetCurrentBranch(ctx context.Context) (string, error) {
+	repo, err := r.open()
 	if err != nil {
-		return nil, fmt.Errorf("error getting current directory: %w", err)
+		return "", fmt.Errorf("not in git repository: %w", err)
 	}
 
-	repo, err := git.PlainOpenWithOptions(pwd, &git.PlainOpenOptions{DetectDotGit: true})
+	head, err := repo.Head()
 	if err != nil {
-		return nil, fmt.Errorf("error opening git repository: %w", err)
+		return "", err
+	}
+
+	if !head.Name().IsBranch() {
+		return "", fmt.Errorf("not on a branch")
 	}
-	return repo, nil
+
+	return head.Name().Short(), nil
 }
 
-// GetCurrentBranch returns the name of the currently checked out branch
-func GetCurrentBranch(ctx context.Context) (string, error) {
-	repo, err := getRepo()
+// GetBranchSHA returns the commit hash a local branch points to
+func (r *Repo) GetBranchSHA(ctx context.Context, branch string) (string, error) {
+	repo, err := r.open()
 	if err != nil {
 		return "", fmt.Errorf("not in git repository: %w", err)
 	}
 
-	head, err := repo.Head()
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(branch), true)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("failed to resolve %s: %w", branch, err)
 	}
 
-	if !head.Name().IsBranch() {
-		return "", fmt.Errorf("not on a branch")
+	return ref.Hash().String(), nil
+}
+
+// ResolveRef returns the commit hash of any revision git understands, such as "main@{upstream}"
+func (r *Repo) ResolveRef(ctx context.Context, ref string) (string, error) {
+	cmd := r.command(ctx, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", ref, err)
 	}
+	return strings.TrimSpace(string(output)), nil
+}
 
-	return head.Name().Short(), nil
+// IsAncestor reports whether ancestor is reachable from descendant
+func (r *Repo) IsAncestor(ctx context.Context, ancestor, descendant string) (bool, error) {
+	cmd := r.command(ctx, "merge-base", "--is-ancestor", ancestor, descendant)
+	err := cmd.Run()
+	if err == nil {
+		return true, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to compare %s and %s: %w", ancestor, descendant, err)
 }
 
+// CountCommits returns the number of commits reachable from to but not from from
+func (r *Repo) CountCommits(ctx context.Context, from, to string) (int, error) {
+	cmd := r.command(ctx, "rev-list", "--count", from+".."+to)
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count commits in %s..%s: %w", from, to, err)
+	}
+	return strconv.Atoi(strings.TrimSpace(string(output)))
+}
+
+// GetGitDir returns the absolute path of the repository's common .git directory
+func (r *Repo) GetGitDir(ctx context.Context) (string, error) {
+	cmd := r.command(ctx, "rev-parse", "--path-format=absolute", "--git-common-dir")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate .git directory: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
 
 // CheckoutBranch checks out a specific branch
-func CheckoutBranch(ctx context.Context, branch string) error {
-	repo, err := getRepo()
+func (r *Repo) CheckoutBranch(ctx context.Context, branch string) error {
+	repo, err := r.open()
 	if err != nil {
 		return fmt.Errorf("not in git repository: %w", err)
 	}
@@ -71,14 +118,14 @@ func CheckoutBranch(ctx context.Context, branch string) error {
 }
 
 // CheckoutAndPull checks out a branch and pulls latest changes
-func CheckoutAndPull(ctx context.Context, branch string) error {
+func (r *Repo) CheckoutAndPull(ctx context.Context, branch string) error {
 	// First checkout the branch
-	if err := CheckoutBranch(ctx, branch); err != nil {
+	if err := r.CheckoutBranch(ctx, branch); err != nil {
 		return err
 	}
 
 	// Then pull using git command
-	cmd := exec.CommandContext(ctx, "git", "pull")
+	cmd := r.command(ctx, "pull")
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to pull %s: %w", branch, err)
 	}
@@ -86,21 +133,178 @@ func CheckoutAndPull(ctx context.Context, branch string) error {
 	return nil
 }
 
-// RebaseOnto rebases current branch onto target branch using git command
-func RebaseOnto(ctx context.Context, target string) error {
-	cmd := exec.CommandContext(ctx, "git", "rebase", target)
+// CheckoutDetached checks out the commit of a branch with a detached HEAD, which works even if
+// the branch is checked out in another worktree
+func (r *Repo) CheckoutDetached(ctx context.Context, branch string) error {
+	cmd := r.command(ctx, "checkout", "--detach", branch)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to checkout %s: %w", branch, err)
+	}
+	return nil
+}
+
+// FastForwardBranch updates a branch that isn't checked out to its remote counterpart,
+// failing if it has commits the remote doesn't
+func (r *Repo) FastForwardBranch(ctx context.Context, branch string) error {
+	cmd := r.command(ctx, "fetch", "origin", "refs/heads/"+branch+":refs/heads/"+branch)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to update %s from origin: %w", branch, err)
+	}
+	return nil
+}
+
+// Fetch updates the remote-tracking branches of origin
+func (r *Repo) Fetch(ctx context.Context) error {
+	cmd := r.command(ctx, "fetch", "origin")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to fetch origin: %w", err)
+	}
+	return nil
+}
+
+// FetchBranch fetches branch from origin and creates a local branch tracking it
+func (r *Repo) FetchBranch(ctx context.Context, branch string) error {
+	cmd := r.command(ctx, "fetch", "origin", "refs/heads/"+branch+":refs/remotes/origin/"+branch)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", branch, err)
+	}
+
+	cmd = r.command(ctx, "branch", "--track", branch, "origin/"+branch)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to create local branch %s: %w", branch, err)
+	}
+	return nil
+}
+
+// CreateBranch creates a branch at the current commit and checks it out
+func (r *Repo) CreateBranch(ctx context.Context, branch string) error {
+	cmd := r.command(ctx, "checkout", "-b", branch)
 	if err := cmd.Run(); err != nil {
-		fmt.Printf("⚠️  Rebase conflict detected on %s\n", target)
-		fmt.Println("   Please resolve conflicts manually and run 'git rebase --continue'")
-		fmt.Println("   Then re-run 'gh stack cascade' to continue")
-		return fmt.Errorf("rebase conflict - manual resolution needed")
+		return fmt.Errorf("failed to create %s: %w", branch, err)
+	}
+	return nil
+}
+
+// HasStagedChanges reports whether the index has changes to commit
+func (r *Repo) HasStagedChanges(ctx context.Context) (bool, error) {
+	cmd := r.command(ctx, "diff", "--cached", "--quiet")
+	err := cmd.Run()
+	if err == nil {
+		return false, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return true, nil
+	}
+	return false, fmt.Errorf("failed to check staged changes: %w", err)
+}
+
+// Commit commits the staged changes with the given message
+func (r *Repo) Commit(ctx context.Context, message string) error {
+	cmd := r.command(ctx, "commit", "-m", message)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// DeleteBranch force-deletes a local branch
+func (r *Repo) DeleteBranch(ctx context.Context, branch string) error {
+	cmd := r.command(ctx, "branch", "-D", branch)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", branch, err)
+	}
+	return nil
+}
+
+// FirstCommitMessage returns the subject and body of the oldest commit on branch that is not on base
+func (r *Repo) FirstCommitMessage(ctx context.Context, base, branch string) (string, string, error) {
+	cmd := r.command(ctx, "rev-list", "--reverse", base+".."+branch)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to list commits in %s..%s: %w", base, branch, err)
+	}
+
+	commits := strings.Fields(string(output))
+	if len(commits) == 0 {
+		return "", "", fmt.Errorf("%s has no commits on top of %s", branch, base)
+	}
+
+	cmd = r.command(ctx, "show", "-s", "--format=%s%x00%b", commits[0])
+	output, err = cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read commit %s: %w", commits[0], err)
+	}
+
+	subject, body, _ := strings.Cut(string(output), "\x00")
+	return subject, strings.TrimSpace(body), nil
+}
+
+// ResetHard resets the current branch and worktree to the given commit
+func (r *Repo) ResetHard(ctx context.Context, sha string) error {
+	cmd := r.command(ctx, "reset", "--hard", sha)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to reset to %s: %w", sha, err)
+	}
+	return nil
+}
+
+// ResetKeep resets the current branch to the given commit, keeping uncommitted changes
+// unless they touch files that differ between the two commits
+func (r *Repo) ResetKeep(ctx context.Context, sha string) error {
+	cmd := r.command(ctx, "reset", "--keep", sha)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to reset to %s: %w", sha, err)
+	}
+	return nil
+}
+
+// UpdateBranch points a branch that isn't checked out at the given commit
+func (r *Repo) UpdateBranch(ctx context.Context, branch, sha string) error {
+	cmd := r.command(ctx, "update-ref", "refs/heads/"+branch, sha)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to update %s: %w", branch, err)
+	}
+	return nil
+}
+
+// PublishBranch pushes branch to origin with force-with-lease and sets it as the branch's upstream
+func (r *Repo) PublishBranch(ctx context.Context, branch string) error {
+	cmd := r.command(ctx, "push", "--force-with-lease", "--set-upstream", "origin", branch)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to push %s: %w", branch, err)
+	}
+	return nil
+}
+
+// PushLease is a branch to force-push and the SHA origin must still have for it, empty if it must not exist
+type PushLease struct {
+	Branch   string
+	Expected string
+}
+
+// PushAtomic force-pushes the branches to origin in one atomic push: either every branch is updated or none is.
+// Each is leased on its expected SHA rather than the remote-tracking ref, which a fetch may have moved.
+func (r *Repo) PushAtomic(ctx context.Context, leases []PushLease) error {
+	args := []string{"push", "--atomic"}
+	var refspecs []string
+	for _, lease := range leases {
+		args = append(args, fmt.Sprintf("--force-with-lease=refs/heads/%s:%s", lease.Branch, lease.Expected))
+		refspecs = append(refspecs, fmt.Sprintf("refs/heads/%s:refs/heads/%s", lease.Branch, lease.Branch))
+	}
+	args = append(args, "origin")
+	args = append(args, refspecs...)
+
+	cmd := r.command(ctx, args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to push branches: %w: %s", err, strings.TrimSpace(string(output)))
 	}
 	return nil
 }
 
 // PushBranch pushes current branch to remote with force-with-lease
-func PushBranch(ctx context.Context) error {
-	cmd := exec.CommandContext(ctx, "git", "push", "--force-with-lease")
+func (r *Repo) PushBranch(ctx context.Context) error {
+	cmd := r.command(ctx, "push", "--force-with-lease")
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to push branch: %w", err)
 	}
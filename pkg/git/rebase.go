@@ -0,0 +1,158 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrRebaseConflict is returned when a rebase stops and needs manual resolution
+var ErrRebaseConflict = errors.New("rebase conflict - manual resolution needed")
+
+// ErrMergeTreeUnsupported is returned when git is too old to simulate a rebase
+var ErrMergeTreeUnsupported = errors.New("predicting conflicts needs git 2.38 or later")
+
+// RebaseOnto rebases the commits of the current branch that come after upstream onto target.
+// Passing the old parent tip as upstream replays only the branch's own commits, which keeps a
+// squash-merged parent's original commits from being replayed. An empty upstream rebases every
+// commit not already in target.
+func (r *Repo) RebaseOnto(ctx context.Context, target, upstream string) error {
+	args := []string{"rebase", target}
+	if upstream != "" {
+		args = []string{"rebase", "--onto", target, upstream}
+	}
+
+	cmd := r.command(ctx, args...)
+	return r.runRebase(ctx, cmd)
+}
+
+// runRebase runs a rebase command, returning ErrRebaseConflict only if the rebase stopped for
+// manual resolution. Failures that leave no rebase behind, like a bad ref or a locked index,
+// are returned with git's message.
+func (r *Repo) runRebase(ctx context.Context, cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+
+	inProgress, checkErr := r.IsRebaseInProgress(ctx)
+	if checkErr != nil {
+		return errors.Join(fmt.Errorf("failed to rebase: %w", err), checkErr)
+	}
+	if inProgress {
+		return ErrRebaseConflict
+	}
+	return fmt.Errorf("failed to rebase: %w: %s", err, strings.TrimSpace(stderr.String()))
+}
+
+// ForkPoint returns the commit branch was forked from base, consulting base's reflog so the
+// result survives base being rewritten, and falling back to the plain merge base
+func (r *Repo) ForkPoint(ctx context.Context, base, branch string) (string, error) {
+	cmd := r.command(ctx, "merge-base", "--fork-point", base, branch)
+	if output, err := cmd.Output(); err == nil {
+		return strings.TrimSpace(string(output)), nil
+	}
+
+	cmd = r.command(ctx, "merge-base", base, branch)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find fork point of %s from %s: %w", branch, base, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// IsRebaseInProgress reports whether a rebase is stopped in the current repository
+func (r *Repo) IsRebaseInProgress(ctx context.Context) (bool, error) {
+	cmd := r.command(ctx, "rev-parse", "--path-format=absolute", "--git-dir")
+	output, err := cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to locate .git directory: %w", err)
+	}
+	gitDir := strings.TrimSpace(string(output))
+
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		if _, err := os.Stat(filepath.Join(gitDir, dir)); err == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RebaseContinue continues a stopped rebase without opening an editor
+func (r *Repo) RebaseContinue(ctx context.Context) error {
+	cmd := r.command(ctx, "rebase", "--continue")
+	cmd.Env = append(os.Environ(), "GIT_EDITOR=true")
+	return r.runRebase(ctx, cmd)
+}
+
+// RebaseAbort aborts a stopped rebase and restores the original branch
+func (r *Repo) RebaseAbort(ctx context.Context) error {
+	cmd := r.command(ctx, "rebase", "--abort")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to abort rebase: %w", err)
+	}
+	return nil
+}
+
+// SimulateRebase predicts rebasing the commits of branch that come after upstream onto target,
+// without touching the worktree or any branch. The commits are merged in one go rather than one
+// by one, so a conflict only one commit in between would hit may go unnoticed. It returns a commit
+// with the resulting tree on top of target, to simulate rebasing descendants onto, or the files
+// that would conflict. Before git 2.38, which added 'git merge-tree --write-tree', it returns
+// ErrMergeTreeUnsupported.
+func (r *Repo) SimulateRebase(ctx context.Context, target, upstream, branch string) (string, []string, error) {
+	// Give both sides upstream as their only parent, so the merge uses it as the merge base
+	ours, err := r.commitTree(ctx, target+"^{tree}", upstream)
+	if err != nil {
+		return "", nil, err
+	}
+	theirs, err := r.commitTree(ctx, branch+"^{tree}", upstream)
+	if err != nil {
+		return "", nil, err
+	}
+
+	cmd := r.command(ctx, "merge-tree", "--write-tree", "--name-only", "--no-messages", ours, theirs)
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 129 {
+		// Older versions only know the trivial merge mode and reject the options with a usage error
+		return "", nil, ErrMergeTreeUnsupported
+	}
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return "", nil, fmt.Errorf("failed to simulate rebasing %s onto %s: %w", branch, target, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if err != nil {
+		// Exit code 1 means conflicts, listed after the tree
+		conflicts := slices.Compact(lines[1:])
+		return "", conflicts, nil
+	}
+
+	result, err := r.commitTree(ctx, lines[0], target)
+	if err != nil {
+		return "", nil, err
+	}
+	return result, nil, nil
+}
+
+// commitTree writes a commit of tree with a single parent without updating any ref
+func (r *Repo) commitTree(ctx context.Context, tree, parent string) (string, error) {
+	cmd := r.command(ctx, "commit-tree", tree, "-p", parent, "-m", "gh-stack simulated rebase")
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=gh-stack", "GIT_AUTHOR_EMAIL=gh-stack@localhost",
+		"GIT_COMMITTER_NAME=gh-stack", "GIT_COMMITTER_EMAIL=gh-stack@localhost")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to write commit of %s: %w", tree, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
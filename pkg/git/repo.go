@@ -0,0 +1,92 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// Git is the set of repository operations the stack commands need
+type Git interface {
+	GetCurrentBranch(ctx context.Context) (string, error)
+	GetBranchSHA(ctx context.Context, branch string) (string, error)
+	ResolveRef(ctx context.Context, ref string) (string, error)
+	IsAncestor(ctx context.Context, ancestor, descendant string) (bool, error)
+	CountCommits(ctx context.Context, from, to string) (int, error)
+	FirstCommitMessage(ctx context.Context, base, branch string) (string, string, error)
+	GetGitDir(ctx context.Context) (string, error)
+
+	CheckoutBranch(ctx context.Context, branch string) error
+	CheckoutAndPull(ctx context.Context, branch string) error
+	CheckoutDetached(ctx context.Context, branch string) error
+	FastForwardBranch(ctx context.Context, branch string) error
+	Fetch(ctx context.Context) error
+	FetchBranch(ctx context.Context, branch string) error
+	CreateBranch(ctx context.Context, branch string) error
+	HasStagedChanges(ctx context.Context) (bool, error)
+	Commit(ctx context.Context, message string) error
+	DeleteBranch(ctx context.Context, branch string) error
+	ResetHard(ctx context.Context, sha string) error
+	ResetKeep(ctx context.Context, sha string) error
+	UpdateBranch(ctx context.Context, branch, sha string) error
+	PushBranch(ctx context.Context) error
+	PublishBranch(ctx context.Context, branch string) error
+	PushAtomic(ctx context.Context, leases []PushLease) error
+
+	RebaseOnto(ctx context.Context, target, upstream string) error
+	ForkPoint(ctx context.Context, base, branch string) (string, error)
+	IsRebaseInProgress(ctx context.Context) (bool, error)
+	RebaseContinue(ctx context.Context) error
+	RebaseAbort(ctx context.Context) error
+	SimulateRebase(ctx context.Context, target, upstream, branch string) (string, []string, error)
+
+	GetModifiedFiles(ctx context.Context) ([]string, error)
+	Stash(ctx context.Context, message string) (string, error)
+	StashPop(ctx context.Context, sha string) error
+
+	AddWorktree(ctx context.Context, path string) error
+	RemoveWorktree(ctx context.Context, path string) error
+
+	SetStackParent(ctx context.Context, branch string, parent StackParent) error
+	GetStackParents(ctx context.Context) (map[string]StackParent, error)
+}
+
+var _ Git = (*Repo)(nil)
+
+// Repo implements Git for the repository containing dir, using go-git for reads
+// and the git command for operations go-git doesn't support
+type Repo struct {
+	dir string
+}
+
+// NewRepo returns the repository containing dir, or the current directory if dir is empty
+func NewRepo(dir string) *Repo {
+	return &Repo{dir: dir}
+}
+
+func (r *Repo) open() (*git.Repository, error) {
+	dir := r.dir
+	if dir == "" {
+		pwd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("error getting current directory: %w", err)
+		}
+		dir = pwd
+	}
+
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("error opening git repository: %w", err)
+	}
+	return repo, nil
+}
+
+// command builds a git command that runs in the repository's directory
+func (r *Repo) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.dir
+	return cmd
+}
@@ -0,0 +1,568 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRepoBranchQueries(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := NewRepo(env.work)
+
+	branch, err := repo.GetCurrentBranch(ctx)
+	if err != nil || branch != "feature" {
+		t.Errorf("GetCurrentBranch() = %q, %v, want feature", branch, err)
+	}
+
+	sha, err := repo.GetBranchSHA(ctx, "feature")
+	if err != nil || sha != env.git(t, "rev-parse", "feature") {
+		t.Errorf("GetBranchSHA() = %q, %v", sha, err)
+	}
+
+	upstream, err := repo.ResolveRef(ctx, "main@{upstream}")
+	if err != nil || upstream != env.git(t, "rev-parse", "origin/main") {
+		t.Errorf("ResolveRef(main@{upstream}) = %q, %v", upstream, err)
+	}
+
+	if _, err := repo.ResolveRef(ctx, "missing"); err == nil {
+		t.Errorf("ResolveRef(missing) should fail")
+	}
+
+	if ok, err := repo.IsAncestor(ctx, "main", "feature"); err != nil || !ok {
+		t.Errorf("IsAncestor(main, feature) = %v, %v, want true", ok, err)
+	}
+	if ok, err := repo.IsAncestor(ctx, "feature", "main"); err != nil || ok {
+		t.Errorf("IsAncestor(feature, main) = %v, %v, want false", ok, err)
+	}
+
+	if count, err := repo.CountCommits(ctx, "main", "feature"); err != nil || count != 1 {
+		t.Errorf("CountCommits(main, feature) = %d, %v, want 1", count, err)
+	}
+
+	subject, body, err := repo.FirstCommitMessage(ctx, "main", "feature")
+	if err != nil || subject != "Feature" || body != "" {
+		t.Errorf("FirstCommitMessage(main, feature) = %q, %q, %v, want Feature", subject, body, err)
+	}
+
+	gitDir, err := repo.GetGitDir(ctx)
+	if err != nil || gitDir != filepath.Join(env.work, ".git") {
+		t.Errorf("GetGitDir() = %q, %v", gitDir, err)
+	}
+}
+
+func TestRepoCheckoutAndPull(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := NewRepo(env.work)
+	remoteMain := env.advanceRemoteMain(t, "main.txt", "main\n")
+
+	if err := repo.CheckoutAndPull(ctx, "main"); err != nil {
+		t.Fatalf("CheckoutAndPull() error = %v", err)
+	}
+
+	if branch, _ := repo.GetCurrentBranch(ctx); branch != "main" {
+		t.Errorf("current branch = %s, want main", branch)
+	}
+	if sha, _ := repo.GetBranchSHA(ctx, "main"); sha != remoteMain {
+		t.Errorf("main = %s, want %s", sha, remoteMain)
+	}
+}
+
+func TestRepoRebaseAndPush(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := NewRepo(env.work)
+	env.advanceRemoteMain(t, "main.txt", "main\n")
+
+	if err := repo.CheckoutAndPull(ctx, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CheckoutBranch(ctx, "feature"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RebaseOnto(ctx, "main", ""); err != nil {
+		t.Fatalf("RebaseOnto() error = %v", err)
+	}
+	if err := repo.PushBranch(ctx); err != nil {
+		t.Fatalf("PushBranch() error = %v", err)
+	}
+
+	if got, want := env.remoteSHA(t, "feature"), env.git(t, "rev-parse", "feature"); got != want {
+		t.Errorf("remote feature = %s, want %s", got, want)
+	}
+	if ok, _ := repo.IsAncestor(ctx, "main", "feature"); !ok {
+		t.Errorf("feature was not rebased onto main")
+	}
+}
+
+func TestRepoRebaseOntoOldParentTip(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := NewRepo(env.work)
+
+	// child is stacked on feature, which is then squash-merged into main
+	env.git(t, "checkout", "-b", "child")
+	env.commit(t, "child.txt", "child\n", "Child")
+	oldParentTip := env.git(t, "rev-parse", "feature")
+
+	env.git(t, "checkout", "main")
+	env.git(t, "merge", "--squash", "feature")
+	env.git(t, "commit", "-m", "Feature (#1)")
+	env.git(t, "checkout", "child")
+
+	if err := repo.RebaseOnto(ctx, "main", oldParentTip); err != nil {
+		t.Fatalf("RebaseOnto() error = %v", err)
+	}
+
+	if count, _ := repo.CountCommits(ctx, "main", "child"); count != 1 {
+		t.Errorf("child has %d commits on top of main, want 1", count)
+	}
+}
+
+func TestRepoRebaseConflict(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := NewRepo(env.work)
+	env.advanceRemoteMain(t, "shared.txt", "main\n")
+
+	if err := repo.CheckoutAndPull(ctx, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CheckoutBranch(ctx, "feature"); err != nil {
+		t.Fatal(err)
+	}
+	before := env.git(t, "rev-parse", "feature")
+
+	err := repo.RebaseOnto(ctx, "main", "")
+	if !errors.Is(err, ErrRebaseConflict) {
+		t.Fatalf("RebaseOnto() error = %v, want ErrRebaseConflict", err)
+	}
+	if inProgress, _ := repo.IsRebaseInProgress(ctx); !inProgress {
+		t.Fatalf("IsRebaseInProgress() = false after conflict")
+	}
+
+	if err := repo.RebaseAbort(ctx); err != nil {
+		t.Fatalf("RebaseAbort() error = %v", err)
+	}
+	if inProgress, _ := repo.IsRebaseInProgress(ctx); inProgress {
+		t.Errorf("IsRebaseInProgress() = true after abort")
+	}
+	if after := env.git(t, "rev-parse", "feature"); after != before {
+		t.Errorf("abort left feature at %s, want %s", after, before)
+	}
+
+	// Conflict again, then resolve and continue
+	if err := repo.RebaseOnto(ctx, "main", ""); !errors.Is(err, ErrRebaseConflict) {
+		t.Fatalf("RebaseOnto() error = %v, want ErrRebaseConflict", err)
+	}
+	if err := repo.RebaseContinue(ctx); !errors.Is(err, ErrRebaseConflict) {
+		t.Errorf("RebaseContinue() with unresolved conflicts error = %v, want ErrRebaseConflict", err)
+	}
+
+	env.write(t, "shared.txt", "resolved\n")
+	env.git(t, "add", "shared.txt")
+	if err := repo.RebaseContinue(ctx); err != nil {
+		t.Fatalf("RebaseContinue() error = %v", err)
+	}
+	if ok, _ := repo.IsAncestor(ctx, "main", "feature"); !ok {
+		t.Errorf("feature was not rebased onto main")
+	}
+}
+
+func TestRepoRebaseFailure(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := NewRepo(env.work)
+
+	// Failures that don't stop a rebase aren't conflicts
+	err := repo.RebaseOnto(ctx, "no-such-branch", "")
+	if err == nil || errors.Is(err, ErrRebaseConflict) {
+		t.Fatalf("RebaseOnto() onto a missing branch error = %v, want a failure other than a conflict", err)
+	}
+	if !strings.Contains(err.Error(), "no-such-branch") {
+		t.Errorf("RebaseOnto() error = %q, want git's message", err)
+	}
+
+	if err := repo.RebaseContinue(ctx); err == nil || errors.Is(err, ErrRebaseConflict) {
+		t.Errorf("RebaseContinue() without a rebase error = %v, want a failure other than a conflict", err)
+	}
+
+	env.advanceRemoteMain(t, "main.txt", "main\n")
+	if err := repo.CheckoutAndPull(ctx, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CheckoutBranch(ctx, "feature"); err != nil {
+		t.Fatal(err)
+	}
+	lock := filepath.Join(env.work, ".git", "index.lock")
+	if err := os.WriteFile(lock, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err = repo.RebaseOnto(ctx, "main", "")
+	if err == nil || errors.Is(err, ErrRebaseConflict) {
+		t.Errorf("RebaseOnto() with a locked index error = %v, want a failure other than a conflict", err)
+	}
+	if inProgress, _ := repo.IsRebaseInProgress(ctx); inProgress {
+		t.Errorf("a rebase was left in progress")
+	}
+}
+
+func TestRepoSimulateRebase(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := NewRepo(env.work)
+
+	// child is stacked on feature, which is then squash-merged into main
+	env.git(t, "checkout", "-b", "child")
+	env.commit(t, "child.txt", "child\n", "Child")
+	oldParentTip := env.git(t, "rev-parse", "feature")
+	env.git(t, "checkout", "main")
+	env.git(t, "merge", "--squash", "feature")
+	env.git(t, "commit", "-m", "Feature (#1)")
+	before := env.git(t, "rev-parse", "main", "feature", "child")
+
+	result, conflicts, err := repo.SimulateRebase(ctx, "main", oldParentTip, "child")
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("SimulateRebase() = %v, %v, want no conflicts", conflicts, err)
+	}
+	if files := env.git(t, "diff", "--name-only", "main", result); files != "child.txt" {
+		t.Errorf("simulated rebase changes %q on top of main, want child.txt", files)
+	}
+
+	// Replaying all of feature's commits again conflicts with its squash
+	env.git(t, "checkout", "feature")
+	env.commit(t, "shared.txt", "feature v2\n", "Feature follow-up")
+	_, conflicts, err = repo.SimulateRebase(ctx, "main", env.git(t, "merge-base", "main", "feature"), "feature")
+	if err != nil || !reflect.DeepEqual(conflicts, []string{"shared.txt"}) {
+		t.Errorf("SimulateRebase() = %v, %v, want conflict in shared.txt", conflicts, err)
+	}
+
+	if after := env.git(t, "rev-parse", "main", "feature~", "child"); after != before {
+		t.Errorf("SimulateRebase() moved branches")
+	}
+	if status := env.git(t, "status", "--porcelain"); status != "" {
+		t.Errorf("SimulateRebase() touched the worktree:\n%s", status)
+	}
+}
+
+func TestRepoSimulateRebaseOldGit(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := NewRepo(env.work)
+
+	// git before 2.38 answers 'merge-tree --write-tree' with a usage error
+	real, err := exec.LookPath("git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := t.TempDir()
+	script := "#!/bin/sh\nif [ \"$1\" = merge-tree ]; then echo 'usage: git merge-tree <base-tree> <branch1> <branch2>' >&2; exit 129; fi\nexec " + real + " \"$@\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "git"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	base := env.git(t, "merge-base", "main", "feature")
+	if _, _, err := repo.SimulateRebase(ctx, "main", base, "feature"); !errors.Is(err, ErrMergeTreeUnsupported) {
+		t.Errorf("SimulateRebase() error = %v, want ErrMergeTreeUnsupported", err)
+	}
+}
+
+func TestRepoPushBranchForceWithLease(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := NewRepo(env.work)
+
+	// A teammate pushes to feature, which we haven't fetched
+	theirs := env.pushFromOther(t, "feature", "teammate.txt", "teammate\n")
+
+	env.git(t, "commit", "--amend", "-m", "Feature, amended")
+	if err := repo.PushBranch(ctx); err == nil {
+		t.Fatalf("PushBranch() overwrote a teammate's unseen push")
+	}
+	if got := env.remoteSHA(t, "feature"); got != theirs {
+		t.Errorf("remote feature = %s, want %s", got, theirs)
+	}
+}
+
+func TestRepoResetAndDelete(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := NewRepo(env.work)
+	mainSHA := env.git(t, "rev-parse", "main")
+
+	env.git(t, "branch", "scratch")
+	if err := repo.ResetHard(ctx, mainSHA); err != nil {
+		t.Fatalf("ResetHard() error = %v", err)
+	}
+	if sha, _ := repo.GetBranchSHA(ctx, "feature"); sha != mainSHA {
+		t.Errorf("feature = %s after reset, want %s", sha, mainSHA)
+	}
+
+	if err := repo.DeleteBranch(ctx, "scratch"); err != nil {
+		t.Fatalf("DeleteBranch() error = %v", err)
+	}
+	if _, err := repo.GetBranchSHA(ctx, "scratch"); err == nil {
+		t.Errorf("scratch still exists after DeleteBranch()")
+	}
+}
+
+func TestRepoForkPoint(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := NewRepo(env.work)
+
+	env.git(t, "checkout", "-b", "child")
+	env.commit(t, "child.txt", "child\n", "Child")
+	oldParentTip := env.git(t, "rev-parse", "feature")
+
+	// Rewriting the parent keeps the old tip in its reflog
+	env.git(t, "checkout", "feature")
+	env.git(t, "commit", "--amend", "-m", "Feature, amended")
+
+	forkPoint, err := repo.ForkPoint(ctx, "feature", "child")
+	if err != nil || forkPoint != oldParentTip {
+		t.Errorf("ForkPoint() = %q, %v, want %s", forkPoint, err, oldParentTip)
+	}
+}
+
+func TestRepoStackParents(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := NewRepo(env.work)
+
+	parents, err := repo.GetStackParents(ctx)
+	if err != nil || len(parents) != 0 {
+		t.Fatalf("GetStackParents() = %v, %v, want none", parents, err)
+	}
+
+	tip := env.git(t, "rev-parse", "main")
+	if err := repo.SetStackParent(ctx, "feature", StackParent{Branch: "main", Tip: tip}); err != nil {
+		t.Fatalf("SetStackParent() error = %v", err)
+	}
+	if err := repo.SetStackParent(ctx, "feature/nested", StackParent{Branch: "feature"}); err != nil {
+		t.Fatalf("SetStackParent() error = %v", err)
+	}
+
+	parents, err = repo.GetStackParents(ctx)
+	expected := map[string]StackParent{
+		"feature":        {Branch: "main", Tip: tip},
+		"feature/nested": {Branch: "feature"},
+	}
+	if err != nil || !reflect.DeepEqual(parents, expected) {
+		t.Errorf("GetStackParents() = %v, %v, want %v", parents, err, expected)
+	}
+
+	// Recording a parent without a tip forgets the old one
+	if err := repo.SetStackParent(ctx, "feature", StackParent{Branch: "main"}); err != nil {
+		t.Fatalf("SetStackParent() error = %v", err)
+	}
+	if parents, _ := repo.GetStackParents(ctx); parents["feature"].Tip != "" {
+		t.Errorf("parent tip = %s after clearing it", parents["feature"].Tip)
+	}
+}
+
+// Helper functions
+
+// testEnv is a throwaway repository with a local bare remote and a second clone
+// standing in for a teammate. The work repository starts on feature, one commit
+// ahead of main, with both branches pushed.
+type testEnv struct {
+	work   string
+	remote string
+	other  string
+}
+
+func TestRepoStash(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := NewRepo(env.work)
+
+	env.write(t, "untracked.txt", "untracked\n")
+	if files, err := repo.GetModifiedFiles(ctx); err != nil || len(files) != 0 {
+		t.Fatalf("GetModifiedFiles() = %v, %v, want none", files, err)
+	}
+
+	env.write(t, "shared.txt", "changed\n")
+	files, err := repo.GetModifiedFiles(ctx)
+	if err != nil || !reflect.DeepEqual(files, []string{"shared.txt"}) {
+		t.Fatalf("GetModifiedFiles() = %v, %v, want [shared.txt]", files, err)
+	}
+
+	sha, err := repo.Stash(ctx, "test")
+	if err != nil {
+		t.Fatalf("Stash() error = %v", err)
+	}
+	if files, _ := repo.GetModifiedFiles(ctx); len(files) != 0 {
+		t.Errorf("modified files after stashing = %v", files)
+	}
+
+	// A stash pushed on top is left alone
+	env.write(t, "untracked.txt", "other\n")
+	env.git(t, "add", "untracked.txt")
+	env.git(t, "stash", "push", "--message", "other")
+
+	if err := repo.StashPop(ctx, sha); err != nil {
+		t.Fatalf("StashPop() error = %v", err)
+	}
+	if content := env.git(t, "diff", "--name-only"); content != "shared.txt" {
+		t.Errorf("changed files after popping = %q, want shared.txt", content)
+	}
+	if stashes := env.git(t, "stash", "list", "--format=%s"); !strings.HasSuffix(stashes, "other") || strings.Contains(stashes, "test") {
+		t.Errorf("stashes after popping = %q, want only the other one", stashes)
+	}
+}
+
+func TestRepoGetModifiedFilesRenamedAndQuoted(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := NewRepo(env.work)
+
+	env.commit(t, "with space ü.txt", "x\n", "Quoted name")
+	env.git(t, "mv", "shared.txt", "renamed.txt")
+	env.write(t, "with space ü.txt", "changed\n")
+
+	files, err := repo.GetModifiedFiles(ctx)
+	if err != nil {
+		t.Fatalf("GetModifiedFiles() error = %v", err)
+	}
+	if expected := []string{"renamed.txt", "with space ü.txt"}; !reflect.DeepEqual(files, expected) {
+		t.Errorf("GetModifiedFiles() = %q, want %q", files, expected)
+	}
+}
+
+func TestRepoPushAtomic(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	repo := NewRepo(env.work)
+
+	oldFeature := env.git(t, "rev-parse", "feature")
+	env.git(t, "checkout", "-b", "child")
+	env.commit(t, "child.txt", "child\n", "Child")
+	env.git(t, "checkout", "feature")
+	env.git(t, "commit", "--amend", "-m", "Feature amended")
+
+	// A stale lease on one branch keeps every branch from being pushed
+	err := repo.PushAtomic(ctx, []PushLease{
+		{Branch: "feature", Expected: env.git(t, "rev-parse", "main")},
+		{Branch: "child"},
+	})
+	if err == nil {
+		t.Fatalf("PushAtomic() with a stale lease succeeded")
+	}
+	if remote := runGit(t, env.remote, "rev-parse", "feature"); remote != oldFeature {
+		t.Errorf("feature was pushed despite the failed push")
+	}
+	if output := runGit(t, env.remote, "branch", "--list", "child"); output != "" {
+		t.Errorf("child was pushed despite the failed push")
+	}
+
+	err = repo.PushAtomic(ctx, []PushLease{
+		{Branch: "feature", Expected: oldFeature},
+		{Branch: "child"},
+	})
+	if err != nil {
+		t.Fatalf("PushAtomic() error = %v", err)
+	}
+	for _, branch := range []string{"feature", "child"} {
+		if remote, local := runGit(t, env.remote, "rev-parse", branch), env.git(t, "rev-parse", branch); remote != local {
+			t.Errorf("%s on origin = %s, want %s", branch, remote, local)
+		}
+	}
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	dir := t.TempDir()
+	env := &testEnv{
+		work:   filepath.Join(dir, "work"),
+		remote: filepath.Join(dir, "remote.git"),
+		other:  filepath.Join(dir, "other"),
+	}
+
+	runGit(t, dir, "init", "--bare", "-b", "main", env.remote)
+	runGit(t, dir, "init", "-b", "main", env.work)
+	configureUser(t, env.work)
+	env.git(t, "remote", "add", "origin", env.remote)
+
+	env.commit(t, "shared.txt", "base\n", "Initial commit")
+	env.git(t, "push", "-u", "origin", "main")
+
+	env.git(t, "checkout", "-b", "feature")
+	env.commit(t, "shared.txt", "feature\n", "Feature")
+	env.git(t, "push", "-u", "origin", "feature")
+
+	runGit(t, dir, "clone", env.remote, env.other)
+	configureUser(t, env.other)
+
+	return env
+}
+
+func (e *testEnv) git(t *testing.T, args ...string) string {
+	t.Helper()
+	return runGit(t, e.work, args...)
+}
+
+func (e *testEnv) write(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(e.work, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (e *testEnv) commit(t *testing.T, name, content, message string) {
+	t.Helper()
+	e.write(t, name, content)
+	e.git(t, "add", name)
+	e.git(t, "commit", "-m", message)
+}
+
+// advanceRemoteMain commits to main from the other clone and returns the new tip
+func (e *testEnv) advanceRemoteMain(t *testing.T, name, content string) string {
+	t.Helper()
+	return e.pushFromOther(t, "main", name, content)
+}
+
+// pushFromOther commits to branch from the other clone, pushes it, and returns the new tip
+func (e *testEnv) pushFromOther(t *testing.T, branch, name, content string) string {
+	t.Helper()
+	runGit(t, e.other, "fetch", "origin")
+	runGit(t, e.other, "checkout", "-B", branch, "origin/"+branch)
+	if err := os.WriteFile(filepath.Join(e.other, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, e.other, "add", name)
+	runGit(t, e.other, "commit", "-m", "Change "+name+" on "+branch)
+	runGit(t, e.other, "push", "origin", branch)
+	return runGit(t, e.other, "rev-parse", "HEAD")
+}
+
+func (e *testEnv) remoteSHA(t *testing.T, branch string) string {
+	t.Helper()
+	return runGit(t, e.remote, "rev-parse", branch)
+}
+
+func configureUser(t *testing.T, dir string) {
+	t.Helper()
+	runGit(t, dir, "config", "user.name", "Test")
+	runGit(t, dir, "config", "user.email", "test@example.com")
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
@@ -0,0 +1,84 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const (
+	stackParentKey    = "stackParent"
+	stackParentTipKey = "stackParentTip"
+)
+
+// StackParent is where a local branch sits in a stack
+type StackParent struct {
+	// Branch is the branch it is stacked on
+	Branch string
+	// Tip is the parent's head when the branch was last based on it, empty if unknown
+	Tip string
+}
+
+// SetStackParent records the branch a local branch is stacked on, and the parent's tip it is based on
+func (r *Repo) SetStackParent(ctx context.Context, branch string, parent StackParent) error {
+	cmd := r.command(ctx, "config", "branch."+branch+"."+stackParentKey, parent.Branch)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to record parent of %s: %w", branch, err)
+	}
+
+	if parent.Tip == "" {
+		cmd = r.command(ctx, "config", "--unset", "branch."+branch+"."+stackParentTipKey)
+		var exitErr *exec.ExitError
+		if err := cmd.Run(); err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 5) {
+			return fmt.Errorf("failed to clear parent tip of %s: %w", branch, err)
+		}
+		return nil
+	}
+
+	cmd = r.command(ctx, "config", "branch."+branch+"."+stackParentTipKey, parent.Tip)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to record parent tip of %s: %w", branch, err)
+	}
+	return nil
+}
+
+// GetStackParents returns the recorded parent of every local branch that has one
+func (r *Repo) GetStackParents(ctx context.Context) (map[string]StackParent, error) {
+	// Config variable names are case-insensitive and reported in lowercase
+	parentSuffix := "." + strings.ToLower(stackParentKey)
+	tipSuffix := "." + strings.ToLower(stackParentTipKey)
+
+	cmd := r.command(ctx, "config", "--get-regexp", `^branch\..*\`+parentSuffix+`(tip)?$`)
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return map[string]StackParent{}, nil // No branch has a recorded parent
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stack parents: %w", err)
+	}
+
+	parents := make(map[string]string)
+	tips := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		key, value, found := strings.Cut(line, " ")
+		if !found {
+			continue
+		}
+		key = strings.TrimPrefix(key, "branch.")
+		if branch, ok := strings.CutSuffix(key, tipSuffix); ok {
+			tips[branch] = value
+		} else {
+			parents[strings.TrimSuffix(key, parentSuffix)] = value
+		}
+	}
+
+	result := make(map[string]StackParent, len(parents))
+	for branch, parent := range parents {
+		result[branch] = StackParent{Branch: parent, Tip: tips[branch]}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,73 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// GetModifiedFiles returns the tracked files with uncommitted changes, staged or not
+func (r *Repo) GetModifiedFiles(ctx context.Context) ([]string, error) {
+	cmd := r.command(ctx, "status", "--porcelain=v1", "-z", "--untracked-files=no")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for uncommitted changes: %w", err)
+	}
+
+	// Each entry is a two letter status, a space and the path, unquoted. Renames and copies
+	// are followed by an entry with the original path.
+	var files []string
+	entries := strings.Split(strings.TrimSuffix(string(output), "\x00"), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		files = append(files, entry[3:])
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++
+		}
+	}
+	return files, nil
+}
+
+// Stash stashes the uncommitted changes to tracked files with the given message and returns
+// the stash commit, so it can be restored even if other stashes are pushed on top of it
+func (r *Repo) Stash(ctx context.Context, message string) (string, error) {
+	cmd := r.command(ctx, "stash", "push", "--message", message)
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to stash changes: %w", err)
+	}
+
+	sha, err := r.ResolveRef(ctx, "refs/stash")
+	if err != nil {
+		return "", fmt.Errorf("failed to find stashed changes: %w", err)
+	}
+	return sha, nil
+}
+
+// StashPop applies the stash commit sha to the current branch and drops its entry from the stash list.
+// If applying it conflicts, the entry is kept.
+func (r *Repo) StashPop(ctx context.Context, sha string) error {
+	cmd := r.command(ctx, "stash", "apply", sha)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to restore stashed changes: %w", err)
+	}
+
+	cmd = r.command(ctx, "stash", "list", "--format=%H")
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to list stashes: %w", err)
+	}
+	i := slices.Index(strings.Fields(string(output)), sha)
+	if i < 0 {
+		return nil // Already dropped
+	}
+
+	cmd = r.command(ctx, "stash", "drop", fmt.Sprintf("stash@{%d}", i))
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to drop stashed changes: %w", err)
+	}
+	return nil
+}
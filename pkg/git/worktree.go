@@ -0,0 +1,31 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// AddWorktree creates a linked worktree at path with a detached HEAD, for working on branches
+// without touching the main checkout
+func (r *Repo) AddWorktree(ctx context.Context, path string) error {
+	cmd := r.command(ctx, "worktree", "add", "--detach", path)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to create worktree at %s: %w", path, err)
+	}
+	return nil
+}
+
+// RemoveWorktree deletes the linked worktree at path, along with any changes in it.
+// Removing a worktree that doesn't exist is not an error.
+func (r *Repo) RemoveWorktree(ctx context.Context, path string) error {
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to remove worktree at %s: %w", path, err)
+	}
+
+	cmd := r.command(ctx, "worktree", "prune")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to prune worktrees: %w", err)
+	}
+	return nil
+}
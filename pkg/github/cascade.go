@@ -0,0 +1,716 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+)
+
+const (
+	cascadeStateDir    = "gh-stack"
+	cascadeStateFile   = "cascade.json"
+	cascadeWorktreeDir = "worktree"
+)
+
+// StepStatus is the progress of a single branch within a cascade
+type StepStatus string
+
+const (
+	StepPending  StepStatus = "pending"
+	StepConflict StepStatus = "conflict"
+	StepDone     StepStatus = "done"
+	StepSkipped  StepStatus = "skipped"
+	// StepFailed is a branch that couldn't be rebased or pushed, left as it was so a cascade of several stacks could carry on
+	StepFailed StepStatus = "failed"
+)
+
+// CascadeStep is the rebase of one branch onto its base
+type CascadeStep struct {
+	Branch      string `json:"branch"`
+	Base        string `json:"base"`
+	OriginalSHA string `json:"originalSha"`
+	OldBaseSHA  string `json:"oldBaseSha"`
+	LocalOnly   bool   `json:"localOnly,omitempty"`
+	// RemoteSHA is the branch's head on origin when the cascade was planned, the lease of an atomic push
+	RemoteSHA string     `json:"remoteSha,omitempty"`
+	Status    StepStatus `json:"status"`
+	// Reason says why a failed step's branch was left as it was
+	Reason string `json:"reason,omitempty"`
+}
+
+// CascadeState is the plan and progress of a cascade, persisted under .git/ so it can be resumed
+type CascadeState struct {
+	OriginalBranch string         `json:"originalBranch"`
+	Steps          []*CascadeStep `json:"steps"`
+	// All is set when every stack is cascaded, so a conflict fails its branch instead of stopping the cascade
+	All bool `json:"all,omitempty"`
+	// Autostash is the stash commit of the uncommitted changes stashed by --autostash, to be restored
+	// on the original branch at the end; empty if none. Other stashes may be pushed on top meanwhile.
+	Autostash string `json:"autostashSha,omitempty"`
+	// Worktree is the temporary worktree rebases run in, leaving the user's checkout alone; empty if none
+	Worktree string `json:"worktree,omitempty"`
+	// Atomic is set when the rebased branches are pushed together at the end instead of one by one
+	Atomic bool `json:"atomic,omitempty"`
+	// Merged are the branches of the merged PRs a sync or merge restacked away from, offered for deletion
+	// once a cascade resumed after a conflict completes
+	Merged []MergedBranch `json:"merged,omitempty"`
+}
+
+// MergedBranch is the head branch of a merged PR
+type MergedBranch struct {
+	Branch string `json:"branch"`
+	Number int    `json:"number"`
+}
+
+// NewCascadeState plans a cascade over the trees in dependency order, recording each branch's current SHA
+// and the parent tip it was forked from so only its own commits are replayed
+func NewCascadeState(ctx context.Context, repo git.Git, originalBranch string, roots ...*TreeNode) (*CascadeState, error) {
+	parents, err := repo.GetStackParents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &CascadeState{OriginalBranch: originalBranch}
+	for _, root := range roots {
+		if err := state.addSteps(ctx, repo, parents, root); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+func (s *CascadeState) addSteps(ctx context.Context, repo git.Git, parents map[string]git.StackParent, node *TreeNode) error {
+	sha, err := repo.GetBranchSHA(ctx, node.PR.HeadRefName)
+	if err != nil {
+		return err
+	}
+
+	oldBase, err := oldBaseSHA(ctx, repo, parents, node.PR)
+	if err != nil {
+		return err
+	}
+
+	s.Steps = append(s.Steps, &CascadeStep{
+		Branch:      node.PR.HeadRefName,
+		Base:        node.PR.BaseRefName,
+		OriginalSHA: sha,
+		OldBaseSHA:  oldBase,
+		LocalOnly:   node.PR.IsLocal,
+		RemoteSHA:   RemoteSHA(ctx, repo, node.PR),
+		Status:      StepPending,
+	})
+
+	for _, child := range node.Children {
+		if err := s.addSteps(ctx, repo, parents, child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseMergedParents replays only a branch's own commits when its PR was retargeted after its parent PR was merged,
+// e.g. squash-merged into main. The fork point from the new base is then the old base's commit, and replaying from
+// it would replay the parent's commits too. A merged PR whose head is part of the branch but not of its base, and
+// newer than the fork point, is the parent the branch was stacked on.
+func (s *CascadeState) UseMergedParents(ctx context.Context, repo git.Git, merged []*PR) error {
+	for _, step := range s.Steps {
+		for _, pr := range merged {
+			if pr.State != "MERGED" || pr.HeadRefName == step.Branch {
+				continue
+			}
+
+			tip, err := mergedParentTip(ctx, repo, pr, step)
+			if err != nil {
+				return err
+			}
+			// Checked against the updated fork point, so the newest of several merged parents wins
+			if tip != "" {
+				step.OldBaseSHA = tip
+			}
+		}
+	}
+	return nil
+}
+
+// mergedParentTip returns the head of the merged PR if the branch of step was stacked on it, preferring
+// the PR's head commit on GitHub over origin/<head>, or "" if it wasn't
+func mergedParentTip(ctx context.Context, repo git.Git, pr *PR, step *CascadeStep) (string, error) {
+	var tips []string
+	if pr.HeadRefOid != "" {
+		tips = append(tips, pr.HeadRefOid)
+	}
+	tips = append(tips, "refs/remotes/origin/"+pr.HeadRefName)
+
+	for _, ref := range tips {
+		tip, err := repo.ResolveRef(ctx, ref)
+		if err != nil || tip == step.OldBaseSHA {
+			continue // Not fetched, or already the fork point
+		}
+
+		inBranch, err := repo.IsAncestor(ctx, tip, step.Branch)
+		if err != nil {
+			return "", err
+		}
+		inBase, err := repo.IsAncestor(ctx, tip, step.Base)
+		if err != nil {
+			return "", err
+		}
+		newer, err := repo.IsAncestor(ctx, step.OldBaseSHA, tip)
+		if err != nil {
+			return "", err
+		}
+		if inBranch && !inBase && newer {
+			return tip, nil
+		}
+	}
+	return "", nil
+}
+
+// oldBaseSHA finds the parent tip the branch of pr was based on, preferring the tip recorded
+// locally over the fork point, which is lost once the parent's reflog expires
+func oldBaseSHA(ctx context.Context, repo git.Git, parents map[string]git.StackParent, pr *PR) (string, error) {
+	if parent, ok := parents[pr.HeadRefName]; ok && parent.Branch == pr.BaseRefName && parent.Tip != "" {
+		based, err := repo.IsAncestor(ctx, parent.Tip, pr.HeadRefName)
+		if err != nil {
+			return "", err
+		}
+		if based {
+			return parent.Tip, nil
+		}
+	}
+
+	return repo.ForkPoint(ctx, pr.BaseRefName, pr.HeadRefName)
+}
+
+// RemoteSHA returns the head of the PR's branch on GitHub, falling back to origin/<branch>,
+// or "" if the branch was never pushed
+func RemoteSHA(ctx context.Context, repo git.Git, pr *PR) string {
+	if pr.IsLocal {
+		return ""
+	}
+	if pr.HeadRefOid != "" {
+		return pr.HeadRefOid
+	}
+	sha, err := repo.ResolveRef(ctx, "refs/remotes/origin/"+pr.HeadRefName)
+	if err != nil {
+		return ""
+	}
+	return sha
+}
+
+// recordParentTip updates the parent tip of a locally tracked branch after it was rebased onto its base
+func recordParentTip(ctx context.Context, repo git.Git, step *CascadeStep) error {
+	parents, err := repo.GetStackParents(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := parents[step.Branch]; !ok {
+		return nil
+	}
+
+	tip, err := repo.ResolveRef(ctx, step.Base)
+	if err != nil {
+		return err
+	}
+	return repo.SetStackParent(ctx, step.Branch, git.StackParent{Branch: step.Base, Tip: tip})
+}
+
+// Step returns the step rebasing branch, or nil if the branch is not part of the cascade
+func (s *CascadeState) Step(branch string) *CascadeStep {
+	for _, step := range s.Steps {
+		if step.Branch == branch {
+			return step
+		}
+	}
+	return nil
+}
+
+// NextStep returns the step to process next, or nil when the cascade is finished
+func (s *CascadeState) NextStep() *CascadeStep {
+	for _, step := range s.Steps {
+		if step.Status == StepPending || step.Status == StepConflict {
+			return step
+		}
+	}
+	return nil
+}
+
+func cascadeStatePath(ctx context.Context, repo git.Git) (string, error) {
+	gitDir, err := repo.GetGitDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(gitDir, cascadeStateDir, cascadeStateFile), nil
+}
+
+// CreateCascadeWorktree sets up a temporary worktree under .git/ for the cascade to rebase in,
+// replacing any left behind by an earlier cascade
+func CreateCascadeWorktree(ctx context.Context, repo git.Git, state *CascadeState) error {
+	gitDir, err := repo.GetGitDir(ctx)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(gitDir, cascadeStateDir, cascadeWorktreeDir)
+	if err := repo.RemoveWorktree(ctx, path); err != nil {
+		return err
+	}
+	if err := repo.AddWorktree(ctx, path); err != nil {
+		return err
+	}
+
+	state.Worktree = path
+	return nil
+}
+
+// rebaseRepo returns the repository rebases run in, the cascade's worktree if it has one
+func (s *CascadeState) rebaseRepo(repo git.Git) git.Git {
+	if s.Worktree == "" {
+		return repo
+	}
+	return git.NewRepo(s.Worktree)
+}
+
+// checkoutStep checks out the branch of step for rebasing. In a worktree it is checked out detached,
+// since the branch may be checked out in the user's checkout.
+func (s *CascadeState) checkoutStep(ctx context.Context, repo git.Git, step *CascadeStep) error {
+	var err error
+	if s.Worktree == "" {
+		err = repo.CheckoutBranch(ctx, step.Branch)
+	} else {
+		err = s.rebaseRepo(repo).CheckoutDetached(ctx, step.Branch)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to checkout %s: %w", step.Branch, err)
+	}
+	return nil
+}
+
+// finishedRebase reports whether the conflicted rebase of step was finished by hand: HEAD is the branch,
+// or the worktree's detached HEAD, and contains the base
+func (s *CascadeState) finishedRebase(ctx context.Context, repo git.Git, step *CascadeStep) (bool, error) {
+	if s.Worktree == "" {
+		current, err := repo.GetCurrentBranch(ctx)
+		if err != nil || current != step.Branch {
+			return false, nil
+		}
+	}
+	return s.rebaseRepo(repo).IsAncestor(ctx, step.Base, "HEAD")
+}
+
+// setBranch points a branch rebased in the worktree at sha. The user's own branch is reset instead,
+// so their checkout follows along; the cascade only starts once it has no uncommitted changes.
+func (s *CascadeState) setBranch(ctx context.Context, repo git.Git, branch, sha string) error {
+	if branch == s.OriginalBranch {
+		return repo.ResetKeep(ctx, sha)
+	}
+	return repo.UpdateBranch(ctx, branch, sha)
+}
+
+// pushStep force-pushes the branch of step by name, since whatever is checked out may not be it
+func (s *CascadeState) pushStep(ctx context.Context, repo git.Git, step *CascadeStep) error {
+	return repo.PublishBranch(ctx, step.Branch)
+}
+
+// LoadCascadeState reads the in-progress cascade, returning nil if there is none
+func LoadCascadeState(ctx context.Context, repo git.Git) (*CascadeState, error) {
+	path, err := cascadeStatePath(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cascade state: %w", err)
+	}
+
+	var state CascadeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse cascade state: %w", err)
+	}
+
+	return &state, nil
+}
+
+// Save persists the cascade state under .git/
+func (s *CascadeState) Save(ctx context.Context, repo git.Git) error {
+	path, err := cascadeStatePath(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cascade state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cascade state: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write cascade state: %w", err)
+	}
+
+	return nil
+}
+
+// ClearCascadeState removes the persisted cascade state and its worktree, if any
+func ClearCascadeState(ctx context.Context, repo git.Git) error {
+	path, err := cascadeStatePath(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	if err := repo.RemoveWorktree(ctx, filepath.Join(filepath.Dir(path), cascadeWorktreeDir)); err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove cascade state: %w", err)
+	}
+	return nil
+}
+
+// RunCascadeStep rebases and pushes a single branch, resuming a stopped rebase if the step previously conflicted
+func RunCascadeStep(ctx context.Context, repo git.Git, state *CascadeState, step *CascadeStep) error {
+	rebaseRepo := state.rebaseRepo(repo)
+
+	rebased := false
+	if step.Status == StepConflict {
+		inProgress, err := rebaseRepo.IsRebaseInProgress(ctx)
+		if err != nil {
+			return err
+		}
+		if inProgress {
+			if err := rebaseRepo.RebaseContinue(ctx); err != nil {
+				return err
+			}
+			rebased = true
+		} else if rebased, err = state.finishedRebase(ctx, repo, step); err != nil {
+			return err
+		}
+
+		// The rebase was aborted, so it's started over, unless the branch was moved some other way
+		if !rebased {
+			sha, err := repo.GetBranchSHA(ctx, step.Branch)
+			if err != nil {
+				return err
+			}
+			if sha != step.OriginalSHA {
+				return fmt.Errorf("%s is not rebased onto %s and was changed since the conflict: check it out and "+
+					"rebase it onto %s, or use 'gh stack cascade --skip' or '--abort'", step.Branch, step.Base, step.Base)
+			}
+		}
+	}
+
+	if !rebased {
+		if err := state.checkoutStep(ctx, repo, step); err != nil {
+			return err
+		}
+
+		if err := rebaseRepo.RebaseOnto(ctx, step.Base, step.OldBaseSHA); err != nil {
+			if errors.Is(err, git.ErrRebaseConflict) {
+				step.Status = StepConflict
+				if saveErr := state.Save(ctx, repo); saveErr != nil {
+					return saveErr
+				}
+			}
+			return err
+		}
+	}
+
+	// The worktree rebased a detached HEAD, move the branch over to it
+	if state.Worktree != "" {
+		sha, err := rebaseRepo.ResolveRef(ctx, "HEAD")
+		if err != nil {
+			return err
+		}
+		if err := state.setBranch(ctx, repo, step.Branch, sha); err != nil {
+			return err
+		}
+	}
+
+	if err := recordParentTip(ctx, repo, step); err != nil {
+		return err
+	}
+
+	// Branches without a PR have nowhere to be pushed yet, and an atomic cascade pushes at the end
+	if !step.LocalOnly && !state.Atomic {
+		if err := state.pushStep(ctx, repo, step); err != nil {
+			return err
+		}
+	}
+
+	step.Status = StepDone
+	if err := state.Save(ctx, repo); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %s\n",
+		completedStyle.Render("✅ Completed"),
+		branchStyle.Render(step.Branch))
+
+	return nil
+}
+
+// SkipCascadeStep abandons the rebase of the conflicted branch and leaves it as it was
+func SkipCascadeStep(ctx context.Context, repo git.Git, state *CascadeState) error {
+	step := state.NextStep()
+	if step == nil || step.Status != StepConflict {
+		return fmt.Errorf("no conflicted branch to skip")
+	}
+
+	if err := abortRebase(ctx, state.rebaseRepo(repo)); err != nil {
+		return err
+	}
+
+	step.Status = StepSkipped
+	return state.Save(ctx, repo)
+}
+
+// FailCascadeStep gives up on a branch that couldn't be rebased or pushed, restoring it and leaving its
+// descendants as they were. reason is listed in the cascade summary.
+func FailCascadeStep(ctx context.Context, repo git.Git, state *CascadeState, step *CascadeStep, reason string) error {
+	if err := abortRebase(ctx, state.rebaseRepo(repo)); err != nil {
+		return err
+	}
+
+	// A branch rebased but not pushed is put back, so its descendants still fit on it
+	sha, err := repo.GetBranchSHA(ctx, step.Branch)
+	if err != nil {
+		return err
+	}
+	if sha != step.OriginalSHA {
+		if err := state.restoreStep(ctx, repo, step); err != nil {
+			return err
+		}
+	}
+
+	step.Status = StepFailed
+	step.Reason = reason
+	failed := map[string]bool{step.Branch: true}
+	for _, s := range state.Steps {
+		if s.Status == StepPending && failed[s.Base] {
+			s.Status = StepSkipped
+			failed[s.Branch] = true
+		}
+	}
+
+	return state.Save(ctx, repo)
+}
+
+// AbortCascade restores every branch touched by the cascade to its pre-cascade SHA,
+// force-pushing the ones that were already pushed, and returns to the original branch
+func AbortCascade(ctx context.Context, repo git.Git, state *CascadeState) error {
+	if err := abortRebase(ctx, state.rebaseRepo(repo)); err != nil {
+		return err
+	}
+
+	for _, step := range state.Steps {
+		if step.Status == StepPending {
+			continue
+		}
+
+		if err := state.restoreStep(ctx, repo, step); err != nil {
+			return err
+		}
+
+		if step.Status == StepDone && !step.LocalOnly && !state.Atomic {
+			if err := state.pushStep(ctx, repo, step); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The user's checkout was never left when rebasing in a worktree
+	if state.Worktree == "" {
+		if err := repo.CheckoutBranch(ctx, state.OriginalBranch); err != nil {
+			return fmt.Errorf("failed to restore branch %s: %w", state.OriginalBranch, err)
+		}
+	}
+
+	return ClearCascadeState(ctx, repo)
+}
+
+// PushCascade force-pushes every branch an atomic cascade rebased in a single atomic push,
+// each leased on the SHA it had on origin when the cascade was planned. Nothing is pushed if any lease fails.
+func PushCascade(ctx context.Context, repo git.Git, state *CascadeState) error {
+	var leases []git.PushLease
+	for _, step := range state.Steps {
+		if step.Status == StepDone && !step.LocalOnly {
+			leases = append(leases, git.PushLease{Branch: step.Branch, Expected: step.RemoteSHA})
+		}
+	}
+	if len(leases) == 0 {
+		return nil
+	}
+	return repo.PushAtomic(ctx, leases)
+}
+
+// restoreStep points the branch of step back at its pre-cascade SHA
+func (s *CascadeState) restoreStep(ctx context.Context, repo git.Git, step *CascadeStep) error {
+	if s.Worktree != "" {
+		return s.setBranch(ctx, repo, step.Branch, step.OriginalSHA)
+	}
+
+	if err := repo.CheckoutBranch(ctx, step.Branch); err != nil {
+		return fmt.Errorf("failed to checkout %s: %w", step.Branch, err)
+	}
+	return repo.ResetHard(ctx, step.OriginalSHA)
+}
+
+// abortRebase aborts the rebase stopped in repo, if there is one
+func abortRebase(ctx context.Context, repo git.Git) error {
+	inProgress, err := repo.IsRebaseInProgress(ctx)
+	if err != nil {
+		return err
+	}
+	if inProgress {
+		return repo.RebaseAbort(ctx)
+	}
+	return nil
+}
+
+// StepPreview describes what a cascade step would do without running it
+type StepPreview struct {
+	Step      *CascadeStep
+	Commits   int
+	BaseMoved bool
+}
+
+// PreviewCascade computes how many commits each step would replay and whether its base moved.
+// If pullBase is set, bases outside the plan are compared against their upstream, since the cascade pulls them first.
+func PreviewCascade(ctx context.Context, repo git.Git, state *CascadeState, pullBase bool) ([]StepPreview, error) {
+	planned := make(map[string]bool)
+	moved := make(map[string]bool)
+	var previews []StepPreview
+
+	for _, step := range state.Steps {
+		baseRef := step.Base
+		if pullBase && !planned[step.Base] {
+			if upstream, err := repo.ResolveRef(ctx, step.Base+"@{upstream}"); err == nil {
+				baseRef = upstream
+			}
+		}
+
+		upToDate, err := repo.IsAncestor(ctx, baseRef, step.Branch)
+		if err != nil {
+			return nil, err
+		}
+
+		commits, err := repo.CountCommits(ctx, step.OldBaseSHA, step.Branch)
+		if err != nil {
+			return nil, err
+		}
+
+		planned[step.Branch] = true
+		moved[step.Branch] = !upToDate || moved[step.Base]
+		previews = append(previews, StepPreview{
+			Step:      step,
+			Commits:   commits,
+			BaseMoved: moved[step.Branch],
+		})
+	}
+
+	return previews, nil
+}
+
+// PrintCascadePlan prints every checkout, rebase and push a cascade would perform,
+// starting with pulling the pulled base branches. If atomic is set, the branches are pushed together at the end.
+func PrintCascadePlan(previews []StepPreview, pulled []string, originalBranch string, atomic bool) {
+	fmt.Println(processingStyle.Render("Dry run: no branches will be changed"))
+	fmt.Println()
+
+	arrow := arrowStyle.Render("→")
+	for _, baseBranch := range pulled {
+		fmt.Printf("%s checkout %s and pull\n", arrow, baseBranchStyle.Render(baseBranch))
+	}
+
+	var pushed []string
+	for _, preview := range previews {
+		step := preview.Step
+
+		baseStatus := "base unchanged"
+		if preview.BaseMoved {
+			baseStatus = "base moved"
+		}
+		commits := "commits"
+		if preview.Commits == 1 {
+			commits = "commit"
+		}
+
+		fmt.Printf("%s checkout %s\n", arrow, branchStyle.Render(step.Branch))
+		fmt.Printf("  rebase onto %s %s\n", step.Base,
+			numberStyle.Render(fmt.Sprintf("(replays %d %s, %s)", preview.Commits, commits, baseStatus)))
+		if step.LocalOnly {
+			continue
+		}
+		if atomic {
+			pushed = append(pushed, step.Branch)
+		} else {
+			fmt.Printf("  push --force-with-lease\n")
+		}
+	}
+
+	fmt.Printf("%s checkout %s\n", arrow, branchStyle.Render(originalBranch))
+	if len(pushed) > 0 {
+		fmt.Printf("%s push --atomic --force-with-lease %s\n", arrow, strings.Join(pushed, " "))
+	}
+}
+
+// PrintCascadeSummary prints how many branches of each stack were rebased, failed or skipped, and why they failed
+func PrintCascadeSummary(state *CascadeState) {
+	var roots []string
+	stacks := make(map[string][]*CascadeStep)
+	rootOf := make(map[string]string)
+	for _, step := range state.Steps {
+		root, ok := rootOf[step.Base]
+		if !ok {
+			root = step.Branch
+			roots = append(roots, root)
+		}
+		rootOf[step.Branch] = root
+		stacks[root] = append(stacks[root], step)
+	}
+
+	fmt.Println()
+	fmt.Println(processingStyle.Render("Cascade summary"))
+	for _, root := range roots {
+		var succeeded, failed, skipped []string
+		for _, step := range stacks[root] {
+			switch step.Status {
+			case StepDone:
+				succeeded = append(succeeded, step.Branch)
+			case StepFailed, StepConflict:
+				if step.Reason != "" {
+					failed = append(failed, fmt.Sprintf("%s: %s", step.Branch, step.Reason))
+				} else {
+					failed = append(failed, step.Branch)
+				}
+			case StepSkipped:
+				skipped = append(skipped, step.Branch)
+			}
+		}
+
+		parts := []string{completedStyle.Render(fmt.Sprintf("✓ %d succeeded", len(succeeded)))}
+		if len(failed) > 0 {
+			parts = append(parts, conflictStyle.Render(fmt.Sprintf("✗ %d failed", len(failed)))+
+				fmt.Sprintf(" (%s)", strings.Join(failed, "; ")))
+		}
+		if len(skipped) > 0 {
+			parts = append(parts, fmt.Sprintf("%d skipped (%s)", len(skipped), strings.Join(skipped, ", ")))
+		}
+		fmt.Printf("  %s → %s: %s\n", branchStyle.Render(root), stacks[root][0].Base, strings.Join(parts, ", "))
+	}
+}
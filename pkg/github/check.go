@@ -0,0 +1,89 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+)
+
+// ConflictPrediction is the expected outcome of rebasing one branch of a cascade
+type ConflictPrediction struct {
+	Step *CascadeStep
+	// Conflicts lists the files the rebase would conflict on
+	Conflicts []string
+	// BlockedBy is the ancestor whose conflict keeps this branch from being predicted
+	BlockedBy string
+}
+
+// PredictConflicts simulates every rebase of the cascade in dependency order, each branch onto
+// the simulated result of its parent, without touching the worktree or any branch.
+// If pullBase is set, bases outside the plan are taken from their upstream, since the cascade pulls them first.
+func PredictConflicts(ctx context.Context, repo git.Git, state *CascadeState, pullBase bool) ([]*ConflictPrediction, error) {
+	rebased := make(map[string]string)
+	blocked := make(map[string]string)
+	var predictions []*ConflictPrediction
+
+	for _, step := range state.Steps {
+		prediction := &ConflictPrediction{Step: step}
+		predictions = append(predictions, prediction)
+
+		if blocker, ok := blocked[step.Base]; ok {
+			prediction.BlockedBy = blocker
+			blocked[step.Branch] = blocker
+			continue
+		}
+
+		target, planned := rebased[step.Base]
+		if !planned {
+			target = step.Base
+			if pullBase {
+				if upstream, err := repo.ResolveRef(ctx, step.Base+"@{upstream}"); err == nil {
+					target = upstream
+				}
+			}
+		}
+
+		result, conflicts, err := repo.SimulateRebase(ctx, target, step.OldBaseSHA, step.Branch)
+		if err != nil {
+			return nil, err
+		}
+		if len(conflicts) > 0 {
+			prediction.Conflicts = conflicts
+			blocked[step.Branch] = step.Branch
+			continue
+		}
+		rebased[step.Branch] = result
+	}
+
+	return predictions, nil
+}
+
+// HasConflicts reports whether any predicted rebase conflicts
+func HasConflicts(predictions []*ConflictPrediction) bool {
+	for _, prediction := range predictions {
+		if len(prediction.Conflicts) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintConflictPredictions prints whether each branch would rebase cleanly, and the files of those that wouldn't
+func PrintConflictPredictions(predictions []*ConflictPrediction) {
+	for _, prediction := range predictions {
+		step := prediction.Step
+		switch {
+		case prediction.BlockedBy != "":
+			fmt.Printf("%s %s %s\n", numberStyle.Render("-"), branchStyle.Render(step.Branch),
+				numberStyle.Render(fmt.Sprintf("(blocked by %s)", prediction.BlockedBy)))
+		case len(prediction.Conflicts) > 0:
+			fmt.Printf("%s %s would conflict rebasing onto %s:\n", conflictStyle.Render("✗"), branchStyle.Render(step.Branch), step.Base)
+			for _, file := range prediction.Conflicts {
+				fmt.Printf("    %s\n", file)
+			}
+		default:
+			fmt.Printf("%s %s rebases cleanly onto %s\n", completedStyle.Render("✓"), branchStyle.Render(step.Branch), step.Base)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package github
+
+import (
+	"slices"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Combined CI check states of a PR
+const (
+	ChecksPassing = "SUCCESS"
+	ChecksFailing = "FAILURE"
+	ChecksPending = "PENDING"
+	// ChecksNone is set once a PR's head has gone a while without any checks reporting, so it has no CI to wait for
+	ChecksNone = "NONE"
+)
+
+var (
+	checksPassingStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	checksFailingStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	checksPendingStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+)
+
+// Fragment of the PR queries reading the checks of a PR's head commit
+const statusCheckRollupFragment = `commits(last: 1) {
+	nodes {
+		commit {
+			statusCheckRollup {
+				state
+				contexts(first: 100) {
+					nodes {
+						__typename
+						... on CheckRun { name conclusion }
+						... on StatusContext { context state }
+					}
+				}
+			}
+		}
+	}
+}`
+
+// prNode is a PR as returned by the PR queries, with its checks still nested under the head commit
+type prNode struct {
+	PR
+	Repository struct {
+		NameWithOwner string `json:"nameWithOwner"`
+	} `json:"repository"`
+	Commits struct {
+		Nodes []struct {
+			Commit struct {
+				StatusCheckRollup *statusCheckRollup `json:"statusCheckRollup"`
+			} `json:"commit"`
+		} `json:"nodes"`
+	} `json:"commits"`
+}
+
+type statusCheckRollup struct {
+	State    string `json:"state"`
+	Contexts struct {
+		Nodes []struct {
+			Typename   string `json:"__typename"`
+			Name       string `json:"name"`
+			Conclusion string `json:"conclusion"`
+			Context    string `json:"context"`
+			State      string `json:"state"`
+		} `json:"nodes"`
+	} `json:"contexts"`
+}
+
+// toPR flattens the head commit's checks into CheckState and FailingChecks
+func (n *prNode) toPR() *PR {
+	pr := n.PR
+	if len(n.Commits.Nodes) == 0 || n.Commits.Nodes[0].Commit.StatusCheckRollup == nil {
+		return &pr
+	}
+
+	rollup := n.Commits.Nodes[0].Commit.StatusCheckRollup
+	switch rollup.State {
+	case "SUCCESS":
+		pr.CheckState = ChecksPassing
+	case "FAILURE", "ERROR":
+		pr.CheckState = ChecksFailing
+	case "PENDING", "EXPECTED":
+		pr.CheckState = ChecksPending
+	}
+
+	for _, check := range rollup.Contexts.Nodes {
+		if check.Typename == "CheckRun" && isFailedConclusion(check.Conclusion) {
+			pr.FailingChecks = append(pr.FailingChecks, check.Name)
+		}
+		if check.Typename == "StatusContext" && (check.State == "FAILURE" || check.State == "ERROR") {
+			pr.FailingChecks = append(pr.FailingChecks, check.Context)
+		}
+	}
+
+	return &pr
+}
+
+func isFailedConclusion(conclusion string) bool {
+	return slices.Contains([]string{"FAILURE", "TIMED_OUT", "CANCELLED", "ACTION_REQUIRED", "STARTUP_FAILURE"}, conclusion)
+}
+
+// getChecksIcon returns the indicator of the PR's CI checks, or "" if it has none
+func getChecksIcon(pr *PR) string {
+	switch pr.CheckState {
+	case ChecksPassing:
+		return checksPassingStyle.Render("✓")
+	case ChecksFailing:
+		return checksFailingStyle.Render("✗")
+	case ChecksPending:
+		return checksPendingStyle.Render("●")
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,80 @@
+package github
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestPRNodeToPR(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		expectedState string
+		expectedFails []string
+	}{
+		{
+			name:          "no checks",
+			data:          `{"number": 1, "commits": {"nodes": [{"commit": {"statusCheckRollup": null}}]}}`,
+			expectedState: "",
+		},
+		{
+			name: "passing",
+			data: `{"number": 1, "commits": {"nodes": [{"commit": {"statusCheckRollup": {"state": "SUCCESS", "contexts": {"nodes": [
+				{"__typename": "CheckRun", "name": "build", "conclusion": "SUCCESS"}
+			]}}}}]}}`,
+			expectedState: ChecksPassing,
+		},
+		{
+			name: "failing check runs and statuses",
+			data: `{"number": 1, "commits": {"nodes": [{"commit": {"statusCheckRollup": {"state": "ERROR", "contexts": {"nodes": [
+				{"__typename": "CheckRun", "name": "build", "conclusion": "SUCCESS"},
+				{"__typename": "CheckRun", "name": "test", "conclusion": "TIMED_OUT"},
+				{"__typename": "StatusContext", "context": "ci/legacy", "state": "FAILURE"}
+			]}}}}]}}`,
+			expectedState: ChecksFailing,
+			expectedFails: []string{"test", "ci/legacy"},
+		},
+		{
+			name: "pending",
+			data: `{"number": 1, "commits": {"nodes": [{"commit": {"statusCheckRollup": {"state": "EXPECTED", "contexts": {"nodes": [
+				{"__typename": "StatusContext", "context": "deploy", "state": "EXPECTED"}
+			]}}}}]}}`,
+			expectedState: ChecksPending,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var node prNode
+			if err := json.Unmarshal([]byte(tt.data), &node); err != nil {
+				t.Fatal(err)
+			}
+
+			pr := node.toPR()
+			if pr.Number != 1 || pr.CheckState != tt.expectedState || !reflect.DeepEqual(pr.FailingChecks, tt.expectedFails) {
+				t.Errorf("toPR() = #%d %q %v, want %q %v", pr.Number, pr.CheckState, pr.FailingChecks, tt.expectedState, tt.expectedFails)
+			}
+		})
+	}
+}
+
+func TestGetChecksIcon(t *testing.T) {
+	tests := []struct {
+		state    string
+		expected string
+	}{
+		{ChecksPassing, "✓"},
+		{ChecksFailing, "✗"},
+		{ChecksPending, "●"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if result := ansi.Strip(getChecksIcon(&PR{CheckState: tt.state})); result != tt.expected {
+			t.Errorf("getChecksIcon(%q) = %q, want %q", tt.state, result, tt.expected)
+		}
+	}
+}
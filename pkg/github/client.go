@@ -0,0 +1,315 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	gh "github.com/cli/go-gh/v2"
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/cli/go-gh/v2/pkg/repository"
+)
+
+const (
+	closedPRLimit = 50
+	// closedPRScan bounds how many of the user's recently updated closed PRs, in any repository, are scanned
+	closedPRScan = 500
+	pageSize     = 100
+)
+
+// Client is the GitHub API surface used by the stack commands
+type Client interface {
+	// GetOpenPRs gets up to limit open PRs for the current repository authored by the current user.
+	// The returned bool reports whether more PRs exist beyond the limit.
+	GetOpenPRs(ctx context.Context, limit int) ([]*PR, bool, error)
+	// GetClosedPRs gets the most recently merged or closed PRs authored by the current user
+	GetClosedPRs(ctx context.Context) ([]*PR, error)
+	// RetargetPR changes the base branch of a PR
+	RetargetPR(ctx context.Context, number int, base string) error
+	// CreatePR opens a PR and returns it
+	CreatePR(ctx context.Context, opts CreatePROptions) (*PR, error)
+	// UpdatePRBody replaces the description of a PR
+	UpdatePRBody(ctx context.Context, number int, body string) error
+	// GetPR gets a PR of the current repository by number, whatever its state
+	GetPR(ctx context.Context, number int) (*PR, error)
+	// GetMergeMethods gets the merge methods the repository allows, in order of preference
+	GetMergeMethods(ctx context.Context) ([]string, error)
+	// MergePR merges a PR with the given merge method
+	MergePR(ctx context.Context, number int, method string) error
+}
+
+// Merge methods, in the order they are preferred when the repository allows several
+const (
+	MergeMethodSquash = "squash"
+	MergeMethodMerge  = "merge"
+	MergeMethodRebase = "rebase"
+)
+
+// CreatePROptions describes a PR to open
+type CreatePROptions struct {
+	Head      string
+	Base      string
+	Title     string
+	Body      string
+	Draft     bool
+	Reviewers []string
+}
+
+// ghClient talks to GitHub through go-gh, using the gh CLI's authentication
+type ghClient struct{}
+
+// NewClient returns a Client backed by the gh CLI's authentication
+func NewClient() Client {
+	return &ghClient{}
+}
+
+// Fields of a PR read by every query
+const prFields = `number title body url headRefName baseRefName headRefOid
+	state isDraft mergeable reviewDecision
+	` + statusCheckRollupFragment
+
+const listPRsQuery = `query ListPRs($states: [PullRequestState!], $orderBy: IssueOrderField!, $first: Int!, $after: String) {
+	viewer {
+		pullRequests(states: $states, orderBy: {field: $orderBy, direction: DESC}, first: $first, after: $after) {
+			pageInfo { hasNextPage endCursor }
+			nodes {
+				repository { nameWithOwner }
+				` + prFields + `
+			}
+		}
+	}
+}`
+
+const getPRQuery = `query GetPR($owner: String!, $name: String!, $number: Int!) {
+	repository(owner: $owner, name: $name) {
+		pullRequest(number: $number) {
+			` + prFields + `
+		}
+	}
+}`
+
+const mergeMethodsQuery = `query MergeMethods($owner: String!, $name: String!) {
+	repository(owner: $owner, name: $name) {
+		squashMergeAllowed mergeCommitAllowed rebaseMergeAllowed
+	}
+}`
+
+type listPRsResponse struct {
+	Viewer struct {
+		PullRequests struct {
+			PageInfo struct {
+				HasNextPage bool   `json:"hasNextPage"`
+				EndCursor   string `json:"endCursor"`
+			} `json:"pageInfo"`
+			Nodes []*prNode `json:"nodes"`
+		} `json:"pullRequests"`
+	} `json:"viewer"`
+}
+
+// listPRsOptions selects the PRs listPRs pages through
+type listPRsOptions struct {
+	States  []string
+	OrderBy string
+	Limit   int
+	// MaxScanned bounds how many of the user's PRs, in any repository, are scanned, 0 for all of them
+	MaxScanned int
+}
+
+// graphQLClient is the part of api.GraphQLClient listPRs uses
+type graphQLClient interface {
+	DoWithContext(ctx context.Context, query string, variables map[string]interface{}, response interface{}) error
+}
+
+func (c *ghClient) GetOpenPRs(ctx context.Context, limit int) ([]*PR, bool, error) {
+	client, repo, err := newGraphQLClient()
+	if err != nil {
+		return nil, false, err
+	}
+	return listPRs(ctx, client, repo, listPRsOptions{States: []string{"OPEN"}, OrderBy: "CREATED_AT", Limit: limit})
+}
+
+func (c *ghClient) GetClosedPRs(ctx context.Context) ([]*PR, error) {
+	client, repo, err := newGraphQLClient()
+	if err != nil {
+		return nil, err
+	}
+	prs, _, err := listPRs(ctx, client, repo, listPRsOptions{
+		States:     []string{"MERGED", "CLOSED"},
+		OrderBy:    "UPDATED_AT",
+		Limit:      closedPRLimit,
+		MaxScanned: closedPRScan,
+	})
+	return prs, err
+}
+
+// listPRs pages through the current user's PRs and keeps the ones of the repository, up to opts.Limit. The
+// returned bool reports whether more of them exist beyond the limit. Unlike the search API, the list has no 1000
+// result cap and includes PRs the moment they are opened, and unlike the repository's list it only holds the
+// user's own PRs.
+func listPRs(ctx context.Context, client graphQLClient, repo repository.Repository, opts listPRsOptions) ([]*PR, bool, error) {
+	var prs []*PR
+	var cursor *string
+	scanned := 0
+
+	for {
+		variables := map[string]interface{}{
+			"states":  opts.States,
+			"orderBy": opts.OrderBy,
+			"first":   pageSize,
+			"after":   cursor,
+		}
+
+		var response listPRsResponse
+		if err := client.DoWithContext(ctx, listPRsQuery, variables, &response); err != nil {
+			return nil, false, fmt.Errorf("failed to get PRs: %w", err)
+		}
+
+		page := response.Viewer.PullRequests
+		for _, node := range page.Nodes {
+			if !strings.EqualFold(node.Repository.NameWithOwner, repo.Owner+"/"+repo.Name) {
+				continue
+			}
+			// One more of the user's PRs than fit means the list was cut short
+			if len(prs) == opts.Limit {
+				return prs, true, nil
+			}
+			prs = append(prs, node.toPR())
+		}
+
+		scanned += len(page.Nodes)
+		if !page.PageInfo.HasNextPage || (opts.MaxScanned > 0 && scanned >= opts.MaxScanned) {
+			return prs, false, nil
+		}
+		cursor = &page.PageInfo.EndCursor
+	}
+}
+
+// newGraphQLClient returns a GraphQL client for the host of the current repository, along with the repository
+func newGraphQLClient() (*api.GraphQLClient, repository.Repository, error) {
+	repo, err := repository.Current()
+	if err != nil {
+		return nil, repository.Repository{}, fmt.Errorf("failed to determine repository: %w", err)
+	}
+
+	client, err := api.NewGraphQLClient(api.ClientOptions{Host: repo.Host})
+	if err != nil {
+		return nil, repository.Repository{}, fmt.Errorf("failed to create GitHub client: %w", err)
+	}
+
+	return client, repo, nil
+}
+
+func (c *ghClient) GetPR(ctx context.Context, number int) (*PR, error) {
+	client, repo, err := newGraphQLClient()
+	if err != nil {
+		return nil, err
+	}
+
+	variables := map[string]interface{}{"owner": repo.Owner, "name": repo.Name, "number": number}
+	var response struct {
+		Repository struct {
+			PullRequest *prNode `json:"pullRequest"`
+		} `json:"repository"`
+	}
+	if err := client.DoWithContext(ctx, getPRQuery, variables, &response); err != nil {
+		return nil, fmt.Errorf("failed to get #%d: %w", number, err)
+	}
+	if response.Repository.PullRequest == nil {
+		return nil, fmt.Errorf("failed to get #%d: no such PR", number)
+	}
+
+	return response.Repository.PullRequest.toPR(), nil
+}
+
+func (c *ghClient) GetMergeMethods(ctx context.Context) ([]string, error) {
+	client, repo, err := newGraphQLClient()
+	if err != nil {
+		return nil, err
+	}
+
+	variables := map[string]interface{}{"owner": repo.Owner, "name": repo.Name}
+	var response struct {
+		Repository struct {
+			SquashMergeAllowed bool `json:"squashMergeAllowed"`
+			MergeCommitAllowed bool `json:"mergeCommitAllowed"`
+			RebaseMergeAllowed bool `json:"rebaseMergeAllowed"`
+		} `json:"repository"`
+	}
+	if err := client.DoWithContext(ctx, mergeMethodsQuery, variables, &response); err != nil {
+		return nil, fmt.Errorf("failed to get merge methods: %w", err)
+	}
+
+	var methods []string
+	if response.Repository.SquashMergeAllowed {
+		methods = append(methods, MergeMethodSquash)
+	}
+	if response.Repository.MergeCommitAllowed {
+		methods = append(methods, MergeMethodMerge)
+	}
+	if response.Repository.RebaseMergeAllowed {
+		methods = append(methods, MergeMethodRebase)
+	}
+	return methods, nil
+}
+
+func (c *ghClient) MergePR(ctx context.Context, number int, method string) error {
+	_, _, err := gh.ExecContext(ctx, "pr", "merge", fmt.Sprint(number), "--"+method)
+	if err != nil {
+		return fmt.Errorf("failed to merge #%d: %w", number, err)
+	}
+	return nil
+}
+
+func (c *ghClient) RetargetPR(ctx context.Context, number int, base string) error {
+	_, _, err := gh.ExecContext(ctx, "pr", "edit", fmt.Sprint(number), "--base", base)
+	if err != nil {
+		return fmt.Errorf("failed to retarget #%d onto %s: %w", number, base, err)
+	}
+	return nil
+}
+
+func (c *ghClient) UpdatePRBody(ctx context.Context, number int, body string) error {
+	_, _, err := gh.ExecContext(ctx, "pr", "edit", fmt.Sprint(number), "--body", body)
+	if err != nil {
+		return fmt.Errorf("failed to update description of #%d: %w", number, err)
+	}
+	return nil
+}
+
+func (c *ghClient) CreatePR(ctx context.Context, opts CreatePROptions) (*PR, error) {
+	args := []string{"pr", "create",
+		"--head", opts.Head,
+		"--base", opts.Base,
+		"--title", opts.Title,
+		"--body", opts.Body}
+	if opts.Draft {
+		args = append(args, "--draft")
+	}
+	for _, reviewer := range opts.Reviewers {
+		args = append(args, "--reviewer", reviewer)
+	}
+
+	output, _, err := gh.ExecContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PR for %s: %w", opts.Head, err)
+	}
+
+	// gh prints the URL of the new PR, which ends with its number
+	url := strings.TrimSpace(output.String())
+	number, err := strconv.Atoi(url[strings.LastIndex(url, "/")+1:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PR number from %q: %w", url, err)
+	}
+
+	return &PR{
+		Number:      number,
+		Title:       opts.Title,
+		Body:        opts.Body,
+		URL:         url,
+		HeadRefName: opts.Head,
+		BaseRefName: opts.Base,
+		State:       "OPEN",
+		IsDraft:     opts.Draft,
+	}, nil
+}
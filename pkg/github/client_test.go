@@ -0,0 +1,101 @@
+package github
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/cli/go-gh/v2/pkg/repository"
+)
+
+// fakeGraphQLClient serves the user's PRs in the given repositories in pages of pageSize, the way GitHub lists them
+type fakeGraphQLClient struct {
+	repos []string
+	pages int
+}
+
+func (c *fakeGraphQLClient) DoWithContext(ctx context.Context, query string, variables map[string]interface{}, response interface{}) error {
+	start := 0
+	if after, ok := variables["after"].(*string); ok && after != nil {
+		start, _ = strconv.Atoi(*after)
+	}
+	end := min(start+pageSize, len(c.repos))
+	c.pages++
+
+	res := response.(*listPRsResponse)
+	for i := start; i < end; i++ {
+		node := &prNode{PR: PR{Number: i + 1}}
+		node.Repository.NameWithOwner = c.repos[i]
+		res.Viewer.PullRequests.Nodes = append(res.Viewer.PullRequests.Nodes, node)
+	}
+	res.Viewer.PullRequests.PageInfo.HasNextPage = end < len(c.repos)
+	res.Viewer.PullRequests.PageInfo.EndCursor = strconv.Itoa(end)
+	return nil
+}
+
+func TestListPRs(t *testing.T) {
+	// 250 of the user's PRs alternating between this repository and another, so this one's span three pages
+	var repos []string
+	for i := range 250 {
+		if i%2 == 0 {
+			repos = append(repos, "O/R")
+		} else {
+			repos = append(repos, "o/other")
+		}
+	}
+
+	tests := []struct {
+		name              string
+		opts              listPRsOptions
+		expectedCount     int
+		expectedTruncated bool
+		expectedPages     int
+	}{
+		{
+			name:          "all of the user's PRs",
+			opts:          listPRsOptions{Limit: 500},
+			expectedCount: 125,
+			expectedPages: 3,
+		},
+		{
+			name:          "exactly the limit",
+			opts:          listPRsOptions{Limit: 125},
+			expectedCount: 125,
+			expectedPages: 3,
+		},
+		{
+			name:              "cut short by the limit",
+			opts:              listPRsOptions{Limit: 60},
+			expectedCount:     60,
+			expectedTruncated: true,
+			expectedPages:     2,
+		},
+		{
+			name:          "scan bounded",
+			opts:          listPRsOptions{Limit: 500, MaxScanned: 100},
+			expectedCount: 50,
+			expectedPages: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeGraphQLClient{repos: repos}
+			prs, truncated, err := listPRs(context.Background(), client, repository.Repository{Owner: "o", Name: "r"}, tt.opts)
+			if err != nil {
+				t.Fatalf("listPRs() error = %v", err)
+			}
+			if len(prs) != tt.expectedCount || truncated != tt.expectedTruncated {
+				t.Errorf("listPRs() = %d PRs, truncated %v, want %d, %v", len(prs), truncated, tt.expectedCount, tt.expectedTruncated)
+			}
+			if client.pages != tt.expectedPages {
+				t.Errorf("fetched %d pages, want %d", client.pages, tt.expectedPages)
+			}
+			for _, pr := range prs {
+				if pr.Number%2 == 0 {
+					t.Fatalf("listed #%d, which belongs to another repository", pr.Number)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package github
+
+import (
+	"context"
+
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+)
+
+// How a local branch differs from its remote
+const (
+	// DivergenceBehind means the remote has commits the local branch is missing
+	DivergenceBehind = "behind"
+	// DivergenceDiverged means the local branch and the remote each have commits the other lacks
+	DivergenceDiverged = "diverged"
+	// DivergenceUnfetched means the PR's head is a commit that hasn't been fetched
+	DivergenceUnfetched = "unfetched"
+)
+
+// AnnotateDivergence sets Divergence on every PR in the tree whose local branch would lose commits
+// if force-pushed. The PR's headRefOid is the remote head when known, otherwise origin/<branch>.
+// Local commits that aren't pushed yet are not a divergence.
+func AnnotateDivergence(ctx context.Context, repo git.Git, roots []*TreeNode) error {
+	for _, node := range roots {
+		divergence, err := branchDivergence(ctx, repo, node.PR)
+		if err != nil {
+			return err
+		}
+		node.PR.Divergence = divergence
+
+		if err := AnnotateDivergence(ctx, repo, node.Children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func branchDivergence(ctx context.Context, repo git.Git, pr *PR) (string, error) {
+	local, err := repo.ResolveRef(ctx, "refs/heads/"+pr.HeadRefName)
+	if err != nil {
+		return "", nil // Nothing local to push
+	}
+
+	remote := pr.HeadRefOid
+	if remote == "" {
+		if remote, err = repo.ResolveRef(ctx, "refs/remotes/origin/"+pr.HeadRefName); err != nil {
+			return "", nil // Never pushed
+		}
+	} else if _, err := repo.ResolveRef(ctx, remote); err != nil {
+		return DivergenceUnfetched, nil
+	}
+
+	if remote == local {
+		return "", nil
+	}
+
+	pushed, err := repo.IsAncestor(ctx, remote, local)
+	if err != nil || pushed {
+		return "", err
+	}
+
+	behind, err := repo.IsAncestor(ctx, local, remote)
+	if err != nil {
+		return "", err
+	}
+	if behind {
+		return DivergenceBehind, nil
+	}
+	return DivergenceDiverged, nil
+}
+
+// FindDiverged returns the PRs in the tree whose local branch differs from its remote, in tree order
+func FindDiverged(roots []*TreeNode) []*PR {
+	var diverged []*PR
+	for _, node := range roots {
+		if node.PR.Divergence != "" {
+			diverged = append(diverged, node.PR)
+		}
+		diverged = append(diverged, FindDiverged(node.Children)...)
+	}
+	return diverged
+}
+
+// DescribeDivergence explains a divergence in a few words
+func DescribeDivergence(divergence string) string {
+	switch divergence {
+	case DivergenceBehind:
+		return "behind origin"
+	case DivergenceDiverged:
+		return "diverged from origin"
+	case DivergenceUnfetched:
+		return "origin has commits that aren't fetched"
+	default:
+		return ""
+	}
+}
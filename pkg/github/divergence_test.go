@@ -0,0 +1,51 @@
+package github
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAnnotateDivergence(t *testing.T) {
+	ctx := context.Background()
+	repo := setupStackRepo(t)
+
+	// feature-1 is behind origin, feature-2 has unpushed commits, which is fine
+	feature1 := gitOutput(t, "rev-parse", "feature-1")
+	gitOutput(t, "checkout", "feature-1")
+	commitFile(t, "feature-1.txt", "more\n", "More feature 1")
+	gitOutput(t, "push")
+	gitOutput(t, "reset", "--hard", feature1)
+	gitOutput(t, "checkout", "feature-2")
+	commitFile(t, "feature-2.txt", "more\n", "More feature 2")
+
+	tree := BuildDependencyTree(stackPRs())
+	if err := AnnotateDivergence(ctx, repo, tree); err != nil {
+		t.Fatalf("AnnotateDivergence() error = %v", err)
+	}
+	if got := tree[0].PR.Divergence; got != DivergenceBehind {
+		t.Errorf("feature-1 divergence = %q, want behind", got)
+	}
+	if got := tree[0].Children[0].PR.Divergence; got != "" {
+		t.Errorf("feature-2 divergence = %q, want none", got)
+	}
+
+	// Rewriting pushed history diverges, and a PR head we've never seen is unfetched
+	gitOutput(t, "commit", "--amend", "-m", "Amended")
+	gitOutput(t, "push")
+	gitOutput(t, "reset", "--hard", "HEAD~1")
+	gitOutput(t, "commit", "--allow-empty", "-m", "Local only")
+
+	prs := stackPRs()
+	prs[0].HeadRefOid = strings.Repeat("a", 40)
+	tree = BuildDependencyTree(prs)
+	if err := AnnotateDivergence(ctx, repo, tree); err != nil {
+		t.Fatalf("AnnotateDivergence() error = %v", err)
+	}
+	if got := tree[0].PR.Divergence; got != DivergenceUnfetched {
+		t.Errorf("feature-1 divergence = %q, want unfetched", got)
+	}
+	if got := tree[0].Children[0].PR.Divergence; got != DivergenceDiverged {
+		t.Errorf("feature-2 divergence = %q, want diverged", got)
+	}
+}
@@ -0,0 +1,139 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Formats accepted by 'gh stack --format'
+const (
+	FormatMermaid  = "mermaid"
+	FormatDot      = "dot"
+	FormatMarkdown = "markdown"
+)
+
+// ExportTree renders the stack tree as a Mermaid flowchart, a Graphviz digraph or a markdown list,
+// grouped by base branch like PrintTree
+func ExportTree(roots []*TreeNode, currentBranch, format string) (string, error) {
+	switch format {
+	case FormatMermaid:
+		return exportMermaid(roots, currentBranch), nil
+	case FormatDot:
+		return exportDot(roots, currentBranch), nil
+	case FormatMarkdown:
+		return exportMarkdown(roots, currentBranch), nil
+	default:
+		return "", fmt.Errorf("unknown format %q, expected %s, %s or %s", format, FormatMermaid, FormatDot, FormatMarkdown)
+	}
+}
+
+// graphLabel describes a PR in a graph node: status, number and title, then the branch
+func graphLabel(pr *PR) string {
+	if pr.IsLocal {
+		return fmt.Sprintf("%s %s (no PR)", getStatusIcon(pr), pr.HeadRefName)
+	}
+	return fmt.Sprintf("%s #%d %s\n%s", getStatusIcon(pr), pr.Number, pr.Title, pr.HeadRefName)
+}
+
+// graphWalk calls visit for every node below each base branch with the ID of its parent,
+// numbering nodes in the order PrintTree shows them
+func graphWalk(roots []*TreeNode, visitBase func(id, branch string), visit func(id, parentID string, pr *PR)) {
+	next := 0
+	newID := func() string {
+		next++
+		return fmt.Sprintf("n%d", next-1)
+	}
+
+	var walk func(nodes []*TreeNode, parentID string)
+	walk = func(nodes []*TreeNode, parentID string) {
+		for _, node := range nodes {
+			id := newID()
+			visit(id, parentID, node.PR)
+			walk(node.Children, id)
+		}
+	}
+
+	sortedBranches, branchGroups := groupByBase(roots)
+	for _, baseBranch := range sortedBranches {
+		id := newID()
+		visitBase(id, baseBranch)
+		walk(branchGroups[baseBranch], id)
+	}
+}
+
+func exportMermaid(roots []*TreeNode, currentBranch string) string {
+	escape := strings.NewReplacer("#", "#35;", `"`, "#quot;", "\n", "<br/>")
+
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	b.WriteString("    classDef current stroke-width:3px\n")
+
+	var current string
+	graphWalk(roots,
+		func(id, branch string) {
+			fmt.Fprintf(&b, "    %s[(\"%s\")]\n", id, escape.Replace(branch))
+			if branch == currentBranch {
+				current = id
+			}
+		},
+		func(id, parentID string, pr *PR) {
+			fmt.Fprintf(&b, "    %s[\"%s\"]\n", id, escape.Replace(graphLabel(pr)))
+			fmt.Fprintf(&b, "    %s --> %s\n", parentID, id)
+			if pr.URL != "" {
+				fmt.Fprintf(&b, "    click %s \"%s\"\n", id, pr.URL)
+			}
+			if pr.HeadRefName == currentBranch {
+				current = id
+			}
+		})
+
+	if current != "" {
+		fmt.Fprintf(&b, "    class %s current\n", current)
+	}
+	return b.String()
+}
+
+func exportDot(roots []*TreeNode, currentBranch string) string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	var b strings.Builder
+	b.WriteString("digraph stack {\n")
+	b.WriteString("    node [shape=box];\n")
+
+	attrs := func(branch string) string {
+		if branch == currentBranch {
+			return ", penwidth=3"
+		}
+		return ""
+	}
+	graphWalk(roots,
+		func(id, branch string) {
+			fmt.Fprintf(&b, "    %s [label=\"%s\", shape=cylinder%s];\n", id, escape.Replace(branch), attrs(branch))
+		},
+		func(id, parentID string, pr *PR) {
+			url := ""
+			if pr.URL != "" {
+				url = fmt.Sprintf(", URL=\"%s\"", escape.Replace(pr.URL))
+			}
+			fmt.Fprintf(&b, "    %s [label=\"%s\"%s%s];\n", id, escape.Replace(graphLabel(pr)), url, attrs(pr.HeadRefName))
+			fmt.Fprintf(&b, "    %s -> %s;\n", parentID, id)
+		})
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func exportMarkdown(roots []*TreeNode, currentBranch string) string {
+	var b strings.Builder
+	sortedBranches, branchGroups := groupByBase(roots)
+	for i, baseBranch := range sortedBranches {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "**`%s`**\n\n", baseBranch)
+		for _, root := range branchGroups[baseBranch] {
+			writeMarkdownNode(&b, root, currentBranch, 0)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package github
+
+import "testing"
+
+func exportTestTree() []*TreeNode {
+	return BuildDependencyTree([]*PR{
+		{Number: 1, Title: `Say "hi"`, HeadRefName: "feature-1", BaseRefName: "main", URL: "https://github.com/o/r/pull/1"},
+		{Number: 2, Title: "Second", HeadRefName: "feature-2", BaseRefName: "feature-1", IsDraft: true},
+		{Number: 3, Title: "Hotfix", HeadRefName: "hotfix", BaseRefName: "develop", ReviewDecision: "APPROVED"},
+	})
+}
+
+func TestExportTreeMermaid(t *testing.T) {
+	result, err := ExportTree(exportTestTree(), "feature-2", FormatMermaid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `flowchart TD
+    classDef current stroke-width:3px
+    n0[("develop")]
+    n1["✅ #35;3 Hotfix<br/>hotfix"]
+    n0 --> n1
+    n2[("main")]
+    n3["🔄 #35;1 Say #quot;hi#quot;<br/>feature-1"]
+    n2 --> n3
+    click n3 "https://github.com/o/r/pull/1"
+    n4["📝 #35;2 Second<br/>feature-2"]
+    n3 --> n4
+    class n4 current
+`
+	if result != expected {
+		t.Errorf("ExportTree(mermaid) =\n%s\nwant\n%s", result, expected)
+	}
+}
+
+func TestExportTreeDot(t *testing.T) {
+	result, err := ExportTree(exportTestTree(), "main", FormatDot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `digraph stack {
+    node [shape=box];
+    n0 [label="develop", shape=cylinder];
+    n1 [label="✅ #3 Hotfix\nhotfix"];
+    n0 -> n1;
+    n2 [label="main", shape=cylinder, penwidth=3];
+    n3 [label="🔄 #1 Say \"hi\"\nfeature-1", URL="https://github.com/o/r/pull/1"];
+    n2 -> n3;
+    n4 [label="📝 #2 Second\nfeature-2"];
+    n3 -> n4;
+}
+`
+	if result != expected {
+		t.Errorf("ExportTree(dot) =\n%s\nwant\n%s", result, expected)
+	}
+}
+
+func TestExportTreeMarkdown(t *testing.T) {
+	result, err := ExportTree(exportTestTree(), "feature-2", FormatMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "**`develop`**\n\n" +
+		"- ✅ #3 Hotfix\n" +
+		"\n" +
+		"**`main`**\n\n" +
+		"- 🔄 [#1](https://github.com/o/r/pull/1) Say \"hi\"\n" +
+		"  - 📝 **#2 Second** 👈\n"
+	if result != expected {
+		t.Errorf("ExportTree(markdown) =\n%s\nwant\n%s", result, expected)
+	}
+}
+
+func TestExportTreeUnknownFormat(t *testing.T) {
+	if _, err := ExportTree(exportTestTree(), "main", "svg"); err == nil {
+		t.Errorf("ExportTree(svg) should fail")
+	}
+}
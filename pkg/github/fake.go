@@ -0,0 +1,125 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// FakeClient is an in-memory Client for exercising the stack logic without GitHub
+type FakeClient struct {
+	Open   []*PR
+	Closed []*PR
+
+	// MergeMethods are the merge methods the repository allows, all of them if nil
+	MergeMethods []string
+
+	// Created records every PR opened through CreatePR
+	Created []CreatePROptions
+	// Merged records the method each PR was merged with through MergePR
+	Merged map[int]string
+}
+
+func (c *FakeClient) GetOpenPRs(ctx context.Context, limit int) ([]*PR, bool, error) {
+	prs := copyPRs(c.Open)
+	if len(prs) > limit {
+		return prs[:limit], true, nil
+	}
+	return prs, false, nil
+}
+
+func (c *FakeClient) GetClosedPRs(ctx context.Context) ([]*PR, error) {
+	return copyPRs(c.Closed), nil
+}
+
+func (c *FakeClient) RetargetPR(ctx context.Context, number int, base string) error {
+	pr := c.findOpenPR(number)
+	if pr == nil {
+		return fmt.Errorf("failed to retarget #%d onto %s: no such open PR", number, base)
+	}
+	pr.BaseRefName = base
+	return nil
+}
+
+func (c *FakeClient) CreatePR(ctx context.Context, opts CreatePROptions) (*PR, error) {
+	number := 1
+	for _, pr := range slices.Concat(c.Open, c.Closed) {
+		number = max(number, pr.Number+1)
+	}
+
+	pr := &PR{
+		Number:      number,
+		Title:       opts.Title,
+		Body:        opts.Body,
+		HeadRefName: opts.Head,
+		BaseRefName: opts.Base,
+		State:       "OPEN",
+		IsDraft:     opts.Draft,
+	}
+	c.Open = append(c.Open, pr)
+	c.Created = append(c.Created, opts)
+
+	return copyPRs([]*PR{pr})[0], nil
+}
+
+func (c *FakeClient) UpdatePRBody(ctx context.Context, number int, body string) error {
+	pr := c.findOpenPR(number)
+	if pr == nil {
+		return fmt.Errorf("failed to update description of #%d: no such open PR", number)
+	}
+	pr.Body = body
+	return nil
+}
+
+func (c *FakeClient) GetPR(ctx context.Context, number int) (*PR, error) {
+	for _, pr := range slices.Concat(c.Open, c.Closed) {
+		if pr.Number == number {
+			return copyPRs([]*PR{pr})[0], nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get #%d: no such PR", number)
+}
+
+func (c *FakeClient) GetMergeMethods(ctx context.Context) ([]string, error) {
+	if c.MergeMethods == nil {
+		return []string{MergeMethodSquash, MergeMethodMerge, MergeMethodRebase}, nil
+	}
+	return slices.Clone(c.MergeMethods), nil
+}
+
+// MergePR closes the PR as merged; the fake doesn't change any branch
+func (c *FakeClient) MergePR(ctx context.Context, number int, method string) error {
+	pr := c.findOpenPR(number)
+	if pr == nil {
+		return fmt.Errorf("failed to merge #%d: no such open PR", number)
+	}
+
+	c.Open = slices.DeleteFunc(c.Open, func(open *PR) bool { return open == pr })
+	pr.State = "MERGED"
+	c.Closed = append([]*PR{pr}, c.Closed...)
+
+	if c.Merged == nil {
+		c.Merged = make(map[int]string)
+	}
+	c.Merged[number] = method
+	return nil
+}
+
+func (c *FakeClient) findOpenPR(number int) *PR {
+	for _, pr := range c.Open {
+		if pr.Number == number {
+			return pr
+		}
+	}
+	return nil
+}
+
+// copyPRs returns copies of the PRs so callers can't mutate the fake's state, as with a real API
+func copyPRs(prs []*PR) []*PR {
+	copies := make([]*PR, len(prs))
+	for i, pr := range prs {
+		c := *pr
+		copies[i] = &c
+	}
+	return copies
+}
@@ -0,0 +1,30 @@
+package github
+
+import "strings"
+
+// MergeBlocker explains why a PR can't be landed yet, or returns "" if it is approved and its checks pass.
+// PRs that need no review and branches known to have no checks don't block, checks that haven't reported yet do.
+func MergeBlocker(pr *PR) string {
+	switch {
+	case pr.IsLocal:
+		return "it has no PR yet"
+	case pr.IsDraft:
+		return "it is a draft"
+	case pr.ReviewDecision == "CHANGES_REQUESTED":
+		return "changes were requested"
+	case pr.ReviewDecision == "REVIEW_REQUIRED":
+		return "it is not approved yet"
+	case pr.CheckState == ChecksFailing && len(pr.FailingChecks) > 0:
+		return "checks are failing: " + strings.Join(pr.FailingChecks, ", ")
+	case pr.CheckState == ChecksFailing:
+		return "checks are failing"
+	case pr.CheckState == ChecksPending:
+		return "checks are still running"
+	case pr.Mergeable == "CONFLICTING":
+		return "it conflicts with its base"
+	case pr.CheckState == "":
+		return "its checks haven't reported yet"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,60 @@
+package github
+
+import "testing"
+
+func TestMergeBlocker(t *testing.T) {
+	tests := []struct {
+		name     string
+		pr       *PR
+		expected string
+	}{
+		{
+			name:     "approved and green",
+			pr:       &PR{ReviewDecision: "APPROVED", CheckState: ChecksPassing, Mergeable: "MERGEABLE"},
+			expected: "",
+		},
+		{
+			name:     "no review required and no checks",
+			pr:       &PR{CheckState: ChecksNone},
+			expected: "",
+		},
+		{
+			name:     "checks not reported",
+			pr:       &PR{ReviewDecision: "APPROVED"},
+			expected: "its checks haven't reported yet",
+		},
+		{
+			name:     "draft",
+			pr:       &PR{IsDraft: true, ReviewDecision: "APPROVED"},
+			expected: "it is a draft",
+		},
+		{
+			name:     "review required",
+			pr:       &PR{ReviewDecision: "REVIEW_REQUIRED", CheckState: ChecksPassing},
+			expected: "it is not approved yet",
+		},
+		{
+			name:     "failing checks",
+			pr:       &PR{ReviewDecision: "APPROVED", CheckState: ChecksFailing, FailingChecks: []string{"build", "lint"}},
+			expected: "checks are failing: build, lint",
+		},
+		{
+			name:     "pending checks",
+			pr:       &PR{ReviewDecision: "APPROVED", CheckState: ChecksPending},
+			expected: "checks are still running",
+		},
+		{
+			name:     "conflicting",
+			pr:       &PR{ReviewDecision: "APPROVED", Mergeable: "CONFLICTING"},
+			expected: "it conflicts with its base",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeBlocker(tt.pr); got != tt.expected {
+				t.Errorf("MergeBlocker() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
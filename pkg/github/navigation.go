@@ -0,0 +1,61 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Markers delimiting the stack navigation section in a PR description
+const (
+	navigationStart = "<!-- gh-stack:start -->"
+	navigationEnd   = "<!-- gh-stack:end -->"
+)
+
+// FormatStackNavigation renders the stack rooted at root as a markdown list for the
+// description of current, with status icons and the current PR marked
+func FormatStackNavigation(root *TreeNode, current *PR) string {
+	var b strings.Builder
+	b.WriteString(navigationStart + "\n")
+	fmt.Fprintf(&b, "**Stack** (based on `%s`)\n\n", root.PR.BaseRefName)
+	writeMarkdownNode(&b, root, current.HeadRefName, 0)
+	b.WriteString(navigationEnd)
+	return b.String()
+}
+
+// writeMarkdownNode writes node and its children as a nested markdown list, marking the current branch
+func writeMarkdownNode(b *strings.Builder, node *TreeNode, currentBranch string, depth int) {
+	pr := node.PR
+	entry := fmt.Sprintf("#%d", pr.Number)
+	if pr.URL != "" {
+		entry = fmt.Sprintf("[#%d](%s)", pr.Number, pr.URL)
+	}
+	if pr.IsLocal {
+		entry = "`" + pr.HeadRefName + "` (no PR)"
+	}
+
+	entry = strings.TrimSpace(entry + " " + pr.Title)
+	if pr.HeadRefName == currentBranch {
+		entry = "**" + entry + "** 👈"
+	}
+	fmt.Fprintf(b, "%s- %s %s\n", strings.Repeat("  ", depth), getStatusIcon(pr), entry)
+
+	for _, child := range node.Children {
+		writeMarkdownNode(b, child, currentBranch, depth+1)
+	}
+}
+
+// InjectStackNavigation replaces the navigation section in body, or appends one if
+// body has none, leaving the rest of the description untouched
+func InjectStackNavigation(body, navigation string) string {
+	start := strings.Index(body, navigationStart)
+	end := strings.Index(body, navigationEnd)
+	if start != -1 && end > start {
+		return body[:start] + navigation + body[end+len(navigationEnd):]
+	}
+
+	body = strings.TrimRight(body, "\n")
+	if body == "" {
+		return navigation
+	}
+	return body + "\n\n" + navigation
+}
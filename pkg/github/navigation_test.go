@@ -0,0 +1,73 @@
+package github
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatStackNavigation(t *testing.T) {
+	roots := BuildDependencyTree([]*PR{
+		{Number: 1, Title: "First", HeadRefName: "feature-1", BaseRefName: "main", URL: "https://github.com/o/r/pull/1"},
+		{Number: 2, Title: "Second", HeadRefName: "feature-2", BaseRefName: "feature-1", IsDraft: true},
+		{HeadRefName: "feature-3", BaseRefName: "feature-1", IsLocal: true},
+	})
+
+	result := FormatStackNavigation(roots[0], FindBranchNode(roots, "feature-2").PR)
+
+	expected := navigationStart + "\n" +
+		"**Stack** (based on `main`)\n\n" +
+		"- 🔄 [#1](https://github.com/o/r/pull/1) First\n" +
+		"  - 🌱 `feature-3` (no PR)\n" +
+		"  - 📝 **#2 Second** 👈\n" +
+		navigationEnd
+	if result != expected {
+		t.Errorf("FormatStackNavigation() =\n%s\nwant\n%s", result, expected)
+	}
+}
+
+func TestInjectStackNavigation(t *testing.T) {
+	navigation := navigationStart + "\nnew\n" + navigationEnd
+	stale := navigationStart + "\nold\n" + navigationEnd
+
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "empty body",
+			body:     "",
+			expected: navigation,
+		},
+		{
+			name:     "appends after user text",
+			body:     "Fixes a bug.\n",
+			expected: "Fixes a bug.\n\n" + navigation,
+		},
+		{
+			name:     "replaces existing section in place",
+			body:     "Before\n\n" + stale + "\n\nAfter",
+			expected: "Before\n\n" + navigation + "\n\nAfter",
+		},
+		{
+			name:     "unchanged section",
+			body:     "Before\n\n" + navigation,
+			expected: "Before\n\n" + navigation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := InjectStackNavigation(tt.body, navigation)
+			if result != tt.expected {
+				t.Errorf("InjectStackNavigation() = %q, want %q", result, tt.expected)
+			}
+			if again := InjectStackNavigation(result, navigation); again != result {
+				t.Errorf("InjectStackNavigation() is not idempotent: %q", again)
+			}
+			if strings.Count(result, navigationStart) != 1 {
+				t.Errorf("InjectStackNavigation() = %q, want exactly one section", result)
+			}
+		})
+	}
+}
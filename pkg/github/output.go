@@ -0,0 +1,38 @@
+package github
+
+// StackJSON is the machine-readable form of the stack tree printed by 'gh stack --json'
+type StackJSON struct {
+	CurrentBranch string        `json:"currentBranch"`
+	Stacks        []*StackEntry `json:"stacks"`
+}
+
+// StackEntry is a PR in the stack tree along with its position in it
+type StackEntry struct {
+	*PR
+	Depth     int           `json:"depth"`
+	Parent    string        `json:"parent"`
+	IsCurrent bool          `json:"isCurrent"`
+	Children  []*StackEntry `json:"children"`
+}
+
+// NewStackJSON converts the tree into its machine-readable form
+func NewStackJSON(roots []*TreeNode, currentBranch string) *StackJSON {
+	return &StackJSON{
+		CurrentBranch: currentBranch,
+		Stacks:        newStackEntries(roots, currentBranch, 0),
+	}
+}
+
+func newStackEntries(nodes []*TreeNode, currentBranch string, depth int) []*StackEntry {
+	entries := make([]*StackEntry, len(nodes))
+	for i, node := range nodes {
+		entries[i] = &StackEntry{
+			PR:        node.PR,
+			Depth:     depth,
+			Parent:    node.PR.BaseRefName,
+			IsCurrent: node.PR.HeadRefName == currentBranch,
+			Children:  newStackEntries(node.Children, currentBranch, depth+1),
+		}
+	}
+	return entries
+}
@@ -0,0 +1,50 @@
+package github
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewStackJSON(t *testing.T) {
+	roots := BuildDependencyTree([]*PR{
+		{Number: 1, Title: "First", HeadRefName: "feature-1", BaseRefName: "main"},
+		{Number: 2, Title: "Second", HeadRefName: "feature-2", BaseRefName: "feature-1"},
+	})
+
+	data, err := json.Marshal(NewStackJSON(roots, "feature-2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		CurrentBranch string `json:"currentBranch"`
+		Stacks        []struct {
+			Number    int    `json:"number"`
+			Depth     int    `json:"depth"`
+			Parent    string `json:"parent"`
+			IsCurrent bool   `json:"isCurrent"`
+			Children  []struct {
+				Number    int    `json:"number"`
+				Title     string `json:"title"`
+				Depth     int    `json:"depth"`
+				Parent    string `json:"parent"`
+				IsCurrent bool   `json:"isCurrent"`
+			} `json:"children"`
+		} `json:"stacks"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.CurrentBranch != "feature-2" || len(decoded.Stacks) != 1 {
+		t.Fatalf("NewStackJSON() = %s", data)
+	}
+	root := decoded.Stacks[0]
+	if root.Number != 1 || root.Depth != 0 || root.Parent != "main" || root.IsCurrent || len(root.Children) != 1 {
+		t.Errorf("root entry = %+v", root)
+	}
+	child := root.Children[0]
+	if child.Number != 2 || child.Title != "Second" || child.Depth != 1 || child.Parent != "feature-1" || !child.IsCurrent {
+		t.Errorf("child entry = %+v", child)
+	}
+}
@@ -1,15 +1,13 @@
 package github
 
 import (
-	"context"
-	"encoding/json"
 	"fmt"
 	"slices"
 	"sort"
+	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/tree"
-	gh "github.com/cli/go-gh/v2"
 	"github.com/vladimir-ananiev/gh-stack/pkg/git"
 )
 
@@ -20,12 +18,34 @@ const (
 type PR struct {
 	Number         int    `json:"number"`
 	Title          string `json:"title"`
+	Body           string `json:"body"`
+	URL            string `json:"url"`
 	HeadRefName    string `json:"headRefName"`
 	BaseRefName    string `json:"baseRefName"`
+	HeadRefOid     string `json:"headRefOid"`
 	State          string `json:"state"`
 	IsDraft        bool   `json:"isDraft"`
 	Mergeable      string `json:"mergeable"`
 	ReviewDecision string `json:"reviewDecision,omitempty"`
+	// CheckState is the combined state of the CI checks on the head commit, empty if there are none
+	CheckState string `json:"checkState,omitempty"`
+	// FailingChecks names the checks that failed
+	FailingChecks []string `json:"failingChecks,omitempty"`
+
+	// IsLocal marks a placeholder for a local branch that has no PR yet
+	IsLocal bool `json:"isLocal,omitempty"`
+	// MismatchedBase is the PR's actual base when it disagrees with the locally recorded parent,
+	// which BaseRefName holds instead
+	MismatchedBase string `json:"mismatchedBase,omitempty"`
+	// Behind and Ahead count the commits the branch is missing from its base and has on top of it,
+	// as set by AnnotateRestackStatus
+	Behind int `json:"behind"`
+	Ahead  int `json:"ahead"`
+	// RemoteBehind and RemoteAhead are the same counts for the branch on origin, when it differs from the local one
+	RemoteBehind int `json:"remoteBehind,omitempty"`
+	RemoteAhead  int `json:"remoteAhead,omitempty"`
+	// Divergence is how the local branch differs from its remote, as set by AnnotateDivergence
+	Divergence string `json:"divergence,omitempty"`
 }
 
 type TreeNode struct {
@@ -39,29 +59,89 @@ var (
 	currentStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("11"))
 	numberStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
 	treeStyle       = lipgloss.NewStyle().Padding(1, 0)
-	
+
 	// Cascade operation styles
 	processingStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true)
 	completedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true)
+	conflictStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true)
 	arrowStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	mismatchStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
 )
 
-// GetOpenPRs gets all open PRs for the current repository authored by the current user
-func GetOpenPRs(ctx context.Context) ([]*PR, error) {
-	output, _, err := gh.ExecContext(ctx, "pr", "list",
-		"--json", "number,title,headRefName,baseRefName,state,isDraft,mergeable,reviewDecision",
-		"--state", "open",
-		"--author", "@me")
-	if err != nil {
-		return nil, fmt.Errorf("failed to get PRs: %w", err)
+// MergeStackParents combines open PRs with the parents recorded locally with 'gh stack create'.
+// The local parent decides where a branch sits in the stack: PRs whose base disagrees with it
+// are placed under it and flagged with MismatchedBase, and branches without a PR get a placeholder.
+func MergeStackParents(prs []*PR, parents map[string]git.StackParent) []*PR {
+	result := make([]*PR, 0, len(prs)+len(parents))
+	hasPR := make(map[string]bool)
+	for _, pr := range prs {
+		hasPR[pr.HeadRefName] = true
+
+		merged := *pr
+		if parent, ok := parents[pr.HeadRefName]; ok && parent.Branch != pr.BaseRefName {
+			merged.MismatchedBase = pr.BaseRefName
+			merged.BaseRefName = parent.Branch
+		}
+		result = append(result, &merged)
+	}
+
+	var branches []string
+	for branch := range parents {
+		if !hasPR[branch] {
+			branches = append(branches, branch)
+		}
 	}
+	sort.Strings(branches)
 
-	var prs []*PR
-	if err := json.Unmarshal(output.Bytes(), &prs); err != nil {
-		return nil, fmt.Errorf("failed to parse PR data: %w", err)
+	for _, branch := range branches {
+		result = append(result, &PR{HeadRefName: branch, BaseRefName: parents[branch].Branch, IsLocal: true})
 	}
 
-	return prs, nil
+	return result
+}
+
+// StaleStackParents returns the new parent of each branch whose parent recorded with 'gh stack create' is gone:
+// merged, or neither an open PR's head nor a local branch according to branchExists. Such a branch falls back
+// to its PR's base, or for a branch without a PR to the base its merged parent went into. The recorded tip is
+// kept, since it still marks where the branch's own commits start.
+func StaleStackParents(prs []*PR, parents map[string]git.StackParent, closed []*PR, branchExists func(string) bool) map[string]git.StackParent {
+	byHead := make(map[string]*PR)
+	for _, pr := range prs {
+		byHead[pr.HeadRefName] = pr
+	}
+
+	// PRs are listed newest first, so the first closed PR for a branch wins
+	merged := make(map[string]*PR)
+	for _, pr := range closed {
+		if _, open := byHead[pr.HeadRefName]; open || pr.State != "MERGED" {
+			continue
+		}
+		if _, exists := merged[pr.HeadRefName]; !exists {
+			merged[pr.HeadRefName] = pr
+		}
+	}
+
+	stale := make(map[string]git.StackParent)
+	for branch, parent := range parents {
+		if _, open := byHead[parent.Branch]; open {
+			continue
+		}
+		mergedParent, isMerged := merged[parent.Branch]
+		if !isMerged && branchExists(parent.Branch) {
+			continue
+		}
+
+		newBase := ""
+		if pr, ok := byHead[branch]; ok && pr.BaseRefName != parent.Branch {
+			newBase = pr.BaseRefName
+		} else if isMerged {
+			newBase = mergedParent.BaseRefName
+		}
+		if newBase != "" && newBase != parent.Branch {
+			stale[branch] = git.StackParent{Branch: newBase, Tip: parent.Tip}
+		}
+	}
+	return stale
 }
 
 // BuildDependencyTree builds a tree structure from PRs based on branch relationships using topological sorting
@@ -118,26 +198,16 @@ func buildSubtree(pr *PR, branchToPR map[string]*PR, visited map[string]bool) *T
 	return node
 }
 
-// PrintTree prints the dependency tree with base branches as roots using lipgloss tree
-func PrintTree(roots []*TreeNode, currentBranch string) {
+// PrintTree prints the dependency tree with base branches as roots using lipgloss tree.
+// With verbose, the failing checks of each PR are listed beneath it.
+func PrintTree(roots []*TreeNode, currentBranch string, verbose bool) {
 	if len(roots) == 0 {
 		fmt.Println("No open PRs found")
 		return
 	}
 
 	// Group roots by base branch
-	branchGroups := make(map[string][]*TreeNode)
-	for _, root := range roots {
-		baseBranch := root.PR.BaseRefName
-		branchGroups[baseBranch] = append(branchGroups[baseBranch], root)
-	}
-
-	// Sort base branches for deterministic output
-	var sortedBranches []string
-	for baseBranch := range branchGroups {
-		sortedBranches = append(sortedBranches, baseBranch)
-	}
-	sort.Strings(sortedBranches)
+	sortedBranches, branchGroups := groupByBase(roots)
 
 	// Print each base branch group in sorted order
 	for i, baseBranch := range sortedBranches {
@@ -156,15 +226,78 @@ func PrintTree(roots []*TreeNode, currentBranch string) {
 		}
 		t := tree.Root(baseBranchText)
 		for _, root := range branchGroups[baseBranch] {
-			addPRNodeToTree(t, root, currentBranch)
+			addPRNodeToTree(t, root, currentBranch, verbose)
 		}
 
 		fmt.Println(treeStyle.Render(t.String()))
 	}
 }
 
+// groupByBase groups the roots by the branch they are based on, returning the base branches sorted
+func groupByBase(roots []*TreeNode) ([]string, map[string][]*TreeNode) {
+	branchGroups := make(map[string][]*TreeNode)
+	for _, root := range roots {
+		baseBranch := root.PR.BaseRefName
+		branchGroups[baseBranch] = append(branchGroups[baseBranch], root)
+	}
+
+	// Sort base branches for deterministic output
+	var sortedBranches []string
+	for baseBranch := range branchGroups {
+		sortedBranches = append(sortedBranches, baseBranch)
+	}
+	sort.Strings(sortedBranches)
+
+	return sortedBranches, branchGroups
+}
+
+// TreeLine is one row of the tree printed by PrintTree
+type TreeLine struct {
+	// Branch is the branch on this row
+	Branch string
+	// PR is the PR of the branch, nil for base branches
+	PR *PR
+	// Text is the rendered row, including the tree guides
+	Text string
+}
+
+// TreeLines lays the tree out row by row the way PrintTree does, for interactive pickers
+func TreeLines(roots []*TreeNode, currentBranch string) []TreeLine {
+	var lines []TreeLine
+	sortedBranches, branchGroups := groupByBase(roots)
+	for _, baseBranch := range sortedBranches {
+		text := baseBranchStyle.Render(baseBranch)
+		if baseBranch == currentBranch {
+			text = currentStyle.Render(baseBranch + " ← current")
+		}
+		lines = append(lines, TreeLine{Branch: baseBranch, Text: text})
+		lines = appendTreeLines(lines, branchGroups[baseBranch], "", currentBranch)
+	}
+	return lines
+}
+
+func appendTreeLines(lines []TreeLine, nodes []*TreeNode, indent, currentBranch string) []TreeLine {
+	for i, node := range nodes {
+		enumerator, childIndent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			enumerator, childIndent = "└── ", "    "
+		}
+
+		lines = append(lines, TreeLine{
+			Branch: node.PR.HeadRefName,
+			PR:     node.PR,
+			Text:   arrowStyle.Render(indent+enumerator) + formatPRNode(node.PR, currentBranch),
+		})
+		lines = appendTreeLines(lines, node.Children, indent+childIndent, currentBranch)
+	}
+	return lines
+}
+
 func formatPRNode(pr *PR, currentBranch string) string {
 	status := getStatusIcon(pr)
+	if checks := getChecksIcon(pr); checks != "" {
+		status += " " + checks
+	}
 
 	branchText := pr.HeadRefName
 	if pr.HeadRefName == currentBranch {
@@ -174,29 +307,57 @@ func formatPRNode(pr *PR, currentBranch string) string {
 	}
 
 	numberText := numberStyle.Render(fmt.Sprintf("#%d", pr.Number))
+	if pr.IsLocal {
+		numberText = numberStyle.Render("(no PR)")
+	}
 	title := pr.Title
 	if len(title) > maxTitleLength {
 		title = title[:maxTitleLength-3] + "..."
 	}
 
-	return fmt.Sprintf("%s %s %s %s", status, branchText, numberText, title)
+	text := fmt.Sprintf("%s %s %s %s", status, branchText, numberText, title)
+	if restack := getRestackIndicator(pr); restack != "" {
+		text += " " + restack
+	}
+	if pr.Divergence != "" {
+		text += mismatchStyle.Render(" ⚠ " + DescribeDivergence(pr.Divergence))
+	}
+	if pr.MismatchedBase != "" {
+		text += mismatchStyle.Render(fmt.Sprintf(" (PR targets %s)", pr.MismatchedBase))
+	}
+	return text
 }
 
-func addPRNodeToTree(t *tree.Tree, node *TreeNode, currentBranch string) {
+// formatFailingChecks lists the failing checks of a PR beneath its node, one per line
+func formatFailingChecks(pr *PR) string {
+	var b strings.Builder
+	for _, check := range pr.FailingChecks {
+		b.WriteString("\n  " + checksFailingStyle.Render("✗ "+check))
+	}
+	return b.String()
+}
+
+func addPRNodeToTree(t *tree.Tree, node *TreeNode, currentBranch string, verbose bool) {
 	nodeText := formatPRNode(node.PR, currentBranch)
+	if verbose {
+		nodeText += formatFailingChecks(node.PR)
+	}
 
 	if len(node.Children) == 0 {
 		t.Child(nodeText)
 	} else {
 		childTree := tree.Root(nodeText)
 		for _, child := range node.Children {
-			addPRNodeToTree(childTree, child, currentBranch)
+			addPRNodeToTree(childTree, child, currentBranch, verbose)
 		}
 		t.Child(childTree)
 	}
 }
 
 func getStatusIcon(pr *PR) string {
+	if pr.IsLocal {
+		return "🌱"
+	}
 	if pr.IsDraft {
 		return "📝"
 	}
@@ -212,6 +373,32 @@ func getStatusIcon(pr *PR) string {
 	return "🔄"
 }
 
+// FindBranchNode finds the node whose PR has the given head branch
+func FindBranchNode(roots []*TreeNode, branch string) *TreeNode {
+	for _, root := range roots {
+		if root.PR.HeadRefName == branch {
+			return root
+		}
+		if found := FindBranchNode(root.Children, branch); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// FindBranchPath returns the nodes from the root of the stack down to the node of branch, or nil if it is in no stack
+func FindBranchPath(roots []*TreeNode, branch string) []*TreeNode {
+	for _, root := range roots {
+		if root.PR.HeadRefName == branch {
+			return []*TreeNode{root}
+		}
+		if path := FindBranchPath(root.Children, branch); path != nil {
+			return append([]*TreeNode{root}, path...)
+		}
+	}
+	return nil
+}
+
 // FindCurrentBranchTree finds the tree containing the current branch
 func FindCurrentBranchTree(roots []*TreeNode, currentBranch string) *TreeNode {
 	for _, root := range roots {
@@ -235,34 +422,3 @@ func findBranchInNode(node *TreeNode, targetBranch string) *TreeNode {
 
 	return nil
 }
-
-// ProcessSingleTreeRebase processes a single tree in dependency order for cascading rebase
-func ProcessSingleTreeRebase(ctx context.Context, root *TreeNode) error {
-	return processNodeRebase(ctx, root)
-}
-
-func processNodeRebase(ctx context.Context, node *TreeNode) error {
-	if err := git.CheckoutBranch(ctx, node.PR.HeadRefName); err != nil {
-		return fmt.Errorf("failed to checkout %s: %w", node.PR.HeadRefName, err)
-	}
-
-	if err := git.RebaseOnto(ctx, node.PR.BaseRefName); err != nil {
-		return err // Error already formatted in RebaseOnto
-	}
-
-	if err := git.PushBranch(ctx); err != nil {
-		return fmt.Errorf("failed to push %s: %w", node.PR.HeadRefName, err)
-	}
-
-	fmt.Printf("%s %s\n",
-		completedStyle.Render("✅ Completed"),
-		branchStyle.Render(node.PR.HeadRefName))
-
-	for _, child := range node.Children {
-		if err := processNodeRebase(ctx, child); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
@@ -2,7 +2,11 @@ package github
 
 import (
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
 )
 
 func TestBuildDependencyTree(t *testing.T) {
@@ -150,6 +154,75 @@ func TestBuildDependencyTree(t *testing.T) {
 	}
 }
 
+func TestMergeStackParents(t *testing.T) {
+	prs := []*PR{
+		{Number: 1, HeadRefName: "feature-1", BaseRefName: "main"},
+		{Number: 4, HeadRefName: "feature-4", BaseRefName: "main"},
+	}
+	parents := map[string]git.StackParent{
+		"feature-1": {Branch: "main"},
+		"feature-3": {Branch: "feature-2"},
+		"feature-2": {Branch: "feature-1", Tip: "abc123"},
+		"feature-4": {Branch: "feature-3"},
+	}
+
+	result := MergeStackParents(prs, parents)
+
+	expected := []*PR{
+		{Number: 1, HeadRefName: "feature-1", BaseRefName: "main"},
+		{Number: 4, HeadRefName: "feature-4", BaseRefName: "feature-3", MismatchedBase: "main"},
+		{HeadRefName: "feature-2", BaseRefName: "feature-1", IsLocal: true},
+		{HeadRefName: "feature-3", BaseRefName: "feature-2", IsLocal: true},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("MergeStackParents() = %v, want %v", result, expected)
+	}
+	if prs[1].BaseRefName != "main" || prs[1].MismatchedBase != "" {
+		t.Errorf("MergeStackParents() modified its input")
+	}
+}
+
+func TestStaleStackParents(t *testing.T) {
+	prs := []*PR{
+		{Number: 1, HeadRefName: "feature-1", BaseRefName: "main"},
+		{Number: 2, HeadRefName: "feature-2", BaseRefName: "main"},
+		{Number: 4, HeadRefName: "feature-4", BaseRefName: "feature-3"},
+		{Number: 6, HeadRefName: "feature-6", BaseRefName: "main"},
+	}
+	parents := map[string]git.StackParent{
+		// Still open
+		"feature-1": {Branch: "main"},
+		// Merged, and the PR was retargeted by GitHub
+		"feature-2": {Branch: "merged-1", Tip: "abc123"},
+		// Merged, and the PR still targets it
+		"feature-4": {Branch: "feature-3", Tip: "def456"},
+		// Merged, without a PR of its own
+		"feature-5": {Branch: "merged-1"},
+		// Deleted locally and never merged
+		"feature-6": {Branch: "gone"},
+		// Local branch without a PR yet
+		"feature-7": {Branch: "local"},
+	}
+	closed := []*PR{
+		{Number: 10, HeadRefName: "merged-1", BaseRefName: "main", State: "MERGED"},
+		{Number: 3, HeadRefName: "feature-3", BaseRefName: "develop", State: "MERGED"},
+		{Number: 11, HeadRefName: "local", BaseRefName: "main", State: "CLOSED"},
+	}
+	exists := func(branch string) bool { return branch == "main" || branch == "merged-1" || branch == "local" }
+
+	result := StaleStackParents(prs, parents, closed, exists)
+
+	expected := map[string]git.StackParent{
+		"feature-2": {Branch: "main", Tip: "abc123"},
+		"feature-4": {Branch: "develop", Tip: "def456"},
+		"feature-5": {Branch: "main"},
+		"feature-6": {Branch: "main"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("StaleStackParents() = %v, want %v", result, expected)
+	}
+}
+
 func TestFindCurrentBranchTree(t *testing.T) {
 	roots := []*TreeNode{
 		{
@@ -216,6 +289,75 @@ func TestFindCurrentBranchTree(t *testing.T) {
 	}
 }
 
+func TestFindBranchPath(t *testing.T) {
+	roots := BuildDependencyTree([]*PR{
+		{Number: 1, HeadRefName: "feature-1", BaseRefName: "main"},
+		{Number: 2, HeadRefName: "feature-2", BaseRefName: "feature-1"},
+		{Number: 3, HeadRefName: "feature-3", BaseRefName: "feature-2"},
+		{Number: 4, HeadRefName: "feature-4", BaseRefName: "feature-1"},
+	})
+
+	tests := []struct {
+		name     string
+		branch   string
+		expected []string
+	}{
+		{name: "root", branch: "feature-1", expected: []string{"feature-1"}},
+		{name: "nested", branch: "feature-3", expected: []string{"feature-1", "feature-2", "feature-3"}},
+		{name: "sibling", branch: "feature-4", expected: []string{"feature-1", "feature-4"}},
+		{name: "not found", branch: "main", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result []string
+			for _, node := range FindBranchPath(roots, tt.branch) {
+				result = append(result, node.PR.HeadRefName)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("FindBranchPath() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTreeLines(t *testing.T) {
+	roots := BuildDependencyTree([]*PR{
+		{Number: 1, HeadRefName: "feature-1", BaseRefName: "main"},
+		{Number: 2, HeadRefName: "feature-2", BaseRefName: "feature-1"},
+		{Number: 3, HeadRefName: "feature-3", BaseRefName: "feature-1"},
+		{Number: 4, HeadRefName: "hotfix", BaseRefName: "develop"},
+	})
+
+	lines := TreeLines(roots, "feature-2")
+
+	expected := []struct {
+		branch string
+		prefix string
+	}{
+		{"develop", ""},
+		{"hotfix", "└── "},
+		{"main", ""},
+		{"feature-1", "└── "},
+		{"feature-2", "    ├── "},
+		{"feature-3", "    └── "},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("TreeLines() returned %d lines, want %d", len(lines), len(expected))
+	}
+	for i, line := range lines {
+		if line.Branch != expected[i].branch || !strings.HasPrefix(ansi.Strip(line.Text), expected[i].prefix) {
+			t.Errorf("line %d = %s %q, want %s with prefix %q", i, line.Branch, ansi.Strip(line.Text), expected[i].branch, expected[i].prefix)
+		}
+		if (line.PR == nil) != (expected[i].prefix == "") {
+			t.Errorf("line %d PR = %v, want a PR only for stacked branches", i, line.PR)
+		}
+	}
+	if !containsString(lines[4].Text, "current") {
+		t.Errorf("current branch is not marked")
+	}
+}
+
 func TestGetStatusIcon(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -247,6 +389,11 @@ func TestGetStatusIcon(t *testing.T) {
 			pr:       &PR{},
 			expected: "🔄",
 		},
+		{
+			name:     "local branch without PR",
+			pr:       &PR{HeadRefName: "feature", IsLocal: true},
+			expected: "🌱",
+		},
 		{
 			name:     "draft takes precedence over approval",
 			pr:       &PR{IsDraft: true, ReviewDecision: "APPROVED"},
@@ -318,6 +465,27 @@ func TestFormatPRNode(t *testing.T) {
 	}
 }
 
+func TestFormatPRNodeMismatchedBase(t *testing.T) {
+	pr := &PR{Number: 4, HeadRefName: "feature-4", BaseRefName: "feature-3", MismatchedBase: "main"}
+
+	if result := formatPRNode(pr, "other-branch"); !containsString(result, "PR targets main") {
+		t.Errorf("formatPRNode() should flag a PR whose base disagrees with its local parent")
+	}
+}
+
+func TestFormatPRNodeChecks(t *testing.T) {
+	pr := &PR{Number: 1, HeadRefName: "feature", CheckState: ChecksFailing, FailingChecks: []string{"build", "lint"}}
+
+	if result := formatPRNode(pr, "other-branch"); !containsString(result, "✗") {
+		t.Errorf("formatPRNode() should show the check state")
+	}
+
+	failing := ansi.Strip(formatFailingChecks(pr))
+	if failing != "\n  ✗ build\n  ✗ lint" {
+		t.Errorf("formatFailingChecks() = %q", failing)
+	}
+}
+
 func TestFormatPRNodeTitleTruncation(t *testing.T) {
 	pr := &PR{
 		Number:      123,
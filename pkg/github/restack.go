@@ -0,0 +1,126 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+)
+
+var restackStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true)
+
+// AnnotateRestackStatus sets Behind and Ahead on every PR in the tree by comparing its head with its base's tip,
+// and RemoteBehind and RemoteAhead for its head on origin when that differs from the local one.
+// Branches are read locally, falling back to origin; bases outside the tree are read from origin first,
+// since that is what a cascade rebases onto.
+func AnnotateRestackStatus(ctx context.Context, repo git.Git, roots []*TreeNode) error {
+	for _, root := range roots {
+		base, ok := resolveBranch(ctx, repo, root.PR.BaseRefName, true)
+		if !ok {
+			continue
+		}
+		if err := annotateRestackStatus(ctx, repo, root, base); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func annotateRestackStatus(ctx context.Context, repo git.Git, node *TreeNode, base string) error {
+	local, hasLocal := resolveRef(ctx, repo, "refs/heads/"+node.PR.HeadRefName)
+	remote, hasRemote := resolveRef(ctx, repo, "refs/remotes/origin/"+node.PR.HeadRefName)
+
+	// Neither local nor pushed, so nothing to compare; its children are compared with its base instead
+	head := local
+	switch {
+	case !hasLocal && !hasRemote:
+		head = base
+	case !hasLocal:
+		head = remote
+	}
+
+	var err error
+	if hasLocal || hasRemote {
+		if node.PR.Behind, node.PR.Ahead, err = countBehindAhead(ctx, repo, head, base); err != nil {
+			return err
+		}
+	}
+	// The PR shows the pushed head, which may not have caught up with the local one or the other way around
+	if hasLocal && hasRemote && local != remote {
+		if node.PR.RemoteBehind, node.PR.RemoteAhead, err = countBehindAhead(ctx, repo, remote, base); err != nil {
+			return err
+		}
+	}
+
+	for _, child := range node.Children {
+		if err := annotateRestackStatus(ctx, repo, child, head); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countBehindAhead counts the commits head is missing from base and has on top of it
+func countBehindAhead(ctx context.Context, repo git.Git, head, base string) (behind, ahead int, err error) {
+	if behind, err = repo.CountCommits(ctx, head, base); err != nil {
+		return 0, 0, err
+	}
+	if ahead, err = repo.CountCommits(ctx, base, head); err != nil {
+		return 0, 0, err
+	}
+	return behind, ahead, nil
+}
+
+// resolveBranch resolves a branch to a SHA, from origin first if preferRemote is set and locally first otherwise
+func resolveBranch(ctx context.Context, repo git.Git, branch string, preferRemote bool) (string, bool) {
+	refs := []string{"refs/heads/" + branch, "refs/remotes/origin/" + branch}
+	if preferRemote {
+		refs[0], refs[1] = refs[1], refs[0]
+	}
+
+	for _, ref := range refs {
+		if sha, ok := resolveRef(ctx, repo, ref); ok {
+			return sha, true
+		}
+	}
+	return "", false
+}
+
+// resolveRef resolves a ref to a SHA, reporting whether it exists
+func resolveRef(ctx context.Context, repo git.Git, ref string) (string, bool) {
+	sha, err := repo.ResolveRef(ctx, ref)
+	return sha, err == nil
+}
+
+// NeedsRestack reports whether any branch in the tree, locally or on origin, is missing commits from its base
+func NeedsRestack(roots []*TreeNode) bool {
+	for _, node := range roots {
+		if node.PR.Behind > 0 || node.PR.RemoteBehind > 0 || NeedsRestack(node.Children) {
+			return true
+		}
+	}
+	return false
+}
+
+// getRestackIndicator returns the needs-restack marker with behind/ahead counts, followed by those of the
+// branch on origin if it is behind too, or "" if the branch is up to date
+func getRestackIndicator(pr *PR) string {
+	var indicator string
+	if pr.Behind > 0 {
+		indicator = fmt.Sprintf("⟳ ↓%d ↑%d", pr.Behind, pr.Ahead)
+	}
+	if pr.RemoteBehind > 0 {
+		remote := fmt.Sprintf("origin ↓%d ↑%d", pr.RemoteBehind, pr.RemoteAhead)
+		if indicator == "" {
+			indicator = "⟳ " + remote
+		} else {
+			indicator += " (" + remote + ")"
+		}
+	}
+
+	if indicator == "" {
+		return ""
+	}
+	return restackStyle.Render(indicator)
+}
@@ -0,0 +1,200 @@
+package github
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+)
+
+func TestAnnotateRestackStatus(t *testing.T) {
+	ctx := context.Background()
+	repo := setupStackRepo(t)
+	gitOutput(t, "fetch")
+
+	tree := BuildDependencyTree(stackPRs())
+	if err := AnnotateRestackStatus(ctx, repo, tree); err != nil {
+		t.Fatalf("AnnotateRestackStatus() error = %v", err)
+	}
+
+	// main moved ahead on the remote, feature-2 is still on top of feature-1
+	feature1, feature2 := tree[0].PR, tree[0].Children[0].PR
+	if feature1.Behind != 1 || feature1.Ahead != 1 {
+		t.Errorf("feature-1 ↓%d ↑%d, want ↓1 ↑1", feature1.Behind, feature1.Ahead)
+	}
+	if feature2.Behind != 0 || feature2.Ahead != 1 {
+		t.Errorf("feature-2 ↓%d ↑%d, want ↓0 ↑1", feature2.Behind, feature2.Ahead)
+	}
+	if !NeedsRestack(tree) {
+		t.Errorf("NeedsRestack() = false with feature-1 behind main")
+	}
+
+	// Restack and push both branches, as a cascade would
+	gitOutput(t, "checkout", "feature-1")
+	gitOutput(t, "rebase", "origin/main")
+	gitOutput(t, "checkout", "feature-2")
+	gitOutput(t, "rebase", "feature-1")
+	gitOutput(t, "push", "--force", "origin", "feature-1", "feature-2")
+
+	tree = BuildDependencyTree(stackPRs())
+	if err := AnnotateRestackStatus(ctx, repo, tree); err != nil {
+		t.Fatalf("AnnotateRestackStatus() error = %v", err)
+	}
+	if NeedsRestack(tree) {
+		t.Errorf("NeedsRestack() = true after a restack")
+	}
+}
+
+func TestAnnotateRestackStatusRemoteAndMissingHeads(t *testing.T) {
+	ctx := context.Background()
+	repo := setupStackRepo(t)
+	gitOutput(t, "fetch")
+
+	// feature-1 is rebased locally but not pushed, so its PR is still behind main
+	gitOutput(t, "checkout", "feature-1")
+	gitOutput(t, "rebase", "origin/main")
+	gitOutput(t, "checkout", "feature-2")
+
+	tree := BuildDependencyTree(stackPRs())
+	if err := AnnotateRestackStatus(ctx, repo, tree); err != nil {
+		t.Fatalf("AnnotateRestackStatus() error = %v", err)
+	}
+	feature1, feature2 := tree[0].PR, tree[0].Children[0].PR
+	if feature1.Behind != 0 || feature1.Ahead != 1 || feature1.RemoteBehind != 1 || feature1.RemoteAhead != 1 {
+		t.Errorf("feature-1 ↓%d ↑%d, origin ↓%d ↑%d, want ↓0 ↑1, origin ↓1 ↑1",
+			feature1.Behind, feature1.Ahead, feature1.RemoteBehind, feature1.RemoteAhead)
+	}
+	if feature2.Behind != 2 || feature2.RemoteBehind != 0 {
+		t.Errorf("feature-2 ↓%d, origin ↓%d, want ↓2 compared with the local feature-1, origin ↓0",
+			feature2.Behind, feature2.RemoteBehind)
+	}
+
+	// With feature-1 gone locally and on origin, feature-2 is compared with main instead
+	gitOutput(t, "branch", "-D", "feature-1")
+	gitOutput(t, "update-ref", "-d", "refs/remotes/origin/feature-1")
+	tree = BuildDependencyTree(stackPRs())
+	if err := AnnotateRestackStatus(ctx, repo, tree); err != nil {
+		t.Fatalf("AnnotateRestackStatus() error = %v", err)
+	}
+	feature2 = tree[0].Children[0].PR
+	if feature2.Behind != 1 || feature2.Ahead != 2 {
+		t.Errorf("feature-2 ↓%d ↑%d, want ↓1 ↑2 compared with main", feature2.Behind, feature2.Ahead)
+	}
+}
+
+func TestNeedsRestack(t *testing.T) {
+	roots := BuildDependencyTree([]*PR{
+		{Number: 1, HeadRefName: "feature-1", BaseRefName: "main", Ahead: 1},
+		{Number: 2, HeadRefName: "feature-2", BaseRefName: "feature-1", Ahead: 2},
+	})
+	if NeedsRestack(roots) {
+		t.Errorf("NeedsRestack() = true for an up to date stack")
+	}
+
+	roots[0].PR.RemoteBehind = 1
+	if !NeedsRestack(roots) {
+		t.Errorf("NeedsRestack() = false with a branch behind its base on origin")
+	}
+
+	roots[0].PR.RemoteBehind = 0
+	roots[0].Children[0].PR.Behind = 3
+	if !NeedsRestack(roots) {
+		t.Errorf("NeedsRestack() = false with a nested branch behind its base")
+	}
+}
+
+func TestGetRestackIndicator(t *testing.T) {
+	if result := getRestackIndicator(&PR{Ahead: 2}); result != "" {
+		t.Errorf("getRestackIndicator() = %q for an up to date branch", result)
+	}
+	if result := ansi.Strip(getRestackIndicator(&PR{Behind: 3, Ahead: 2})); result != "⟳ ↓3 ↑2" {
+		t.Errorf("getRestackIndicator() = %q, want ⟳ ↓3 ↑2", result)
+	}
+	if result := ansi.Strip(getRestackIndicator(&PR{Ahead: 2, RemoteBehind: 1, RemoteAhead: 2})); result != "⟳ origin ↓1 ↑2" {
+		t.Errorf("getRestackIndicator() = %q, want ⟳ origin ↓1 ↑2", result)
+	}
+	if result := ansi.Strip(getRestackIndicator(&PR{Behind: 3, Ahead: 2, RemoteBehind: 4, RemoteAhead: 1})); result != "⟳ ↓3 ↑2 (origin ↓4 ↑1)" {
+		t.Errorf("getRestackIndicator() = %q, want ⟳ ↓3 ↑2 (origin ↓4 ↑1)", result)
+	}
+}
+
+func stackPRs() []*PR {
+	return []*PR{
+		{Number: 1, Title: "First", HeadRefName: "feature-1", BaseRefName: "main"},
+		{Number: 2, Title: "Second", HeadRefName: "feature-2", BaseRefName: "feature-1"},
+	}
+}
+
+// setupStackRepo creates a repository with a local bare remote and the stack
+// main ← feature-1 ← feature-2, then moves main ahead on the remote. The test
+// runs from a checkout of feature-2.
+func setupStackRepo(t *testing.T) git.Git {
+	t.Helper()
+
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	work := filepath.Join(dir, "work")
+
+	runGit(t, dir, "init", "--bare", "-b", "main", remote)
+	runGit(t, dir, "init", "-b", "main", work)
+	t.Chdir(work)
+
+	gitOutput(t, "config", "user.name", "Test")
+	gitOutput(t, "config", "user.email", "test@example.com")
+	gitOutput(t, "remote", "add", "origin", remote)
+
+	commitFile(t, "shared.txt", "base\n", "Initial commit")
+	gitOutput(t, "push", "-u", "origin", "main")
+
+	gitOutput(t, "checkout", "-b", "feature-1")
+	commitFile(t, "shared.txt", "feature-1\n", "Feature 1")
+	gitOutput(t, "push", "-u", "origin", "feature-1")
+
+	gitOutput(t, "checkout", "-b", "feature-2")
+	commitFile(t, "feature-2.txt", "feature-2\n", "Feature 2")
+	gitOutput(t, "push", "-u", "origin", "feature-2")
+
+	// Move main ahead on the remote from a second clone
+	other := filepath.Join(dir, "other")
+	runGit(t, dir, "clone", "-b", "main", remote, other)
+	runGit(t, other, "config", "user.name", "Test")
+	runGit(t, other, "config", "user.email", "test@example.com")
+	if err := os.WriteFile(filepath.Join(other, "main.txt"), []byte("main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, other, "add", ".")
+	runGit(t, other, "commit", "-m", "Main moves")
+	runGit(t, other, "push", "origin", "main")
+
+	return git.NewRepo("")
+}
+
+func commitFile(t *testing.T, name, content, message string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitOutput(t, "add", name)
+	gitOutput(t, "commit", "-m", message)
+}
+
+func gitOutput(t *testing.T, args ...string) string {
+	t.Helper()
+	return runGit(t, "", args...)
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
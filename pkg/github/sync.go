@@ -0,0 +1,75 @@
+package github
+
+import "github.com/vladimir-ananiev/gh-stack/pkg/git"
+
+// Orphan is an open PR whose parent PR has been merged or closed
+type Orphan struct {
+	PR     *PR
+	Parent *PR
+	// NewBase is the branch the merged parent went into; empty if the parent was closed without merging
+	NewBase string
+}
+
+// FindOrphanedPRs finds open PRs based on the head branch of a merged or closed PR.
+// Orphans of a merged parent get the parent's base as their new base, following chains
+// of merged parents until it reaches a branch that is still open or was never a PR.
+// Orphans whose chain ends at a PR closed without merging are returned as unmerged instead,
+// with that PR as their parent, since whether its commits belong in the stack is up to the user.
+// A PR whose parent recorded with 'gh stack create' was merged is an orphan too, even once GitHub
+// retargeted it after the parent's branch was deleted.
+func FindOrphanedPRs(open, closed []*PR, parents map[string]git.StackParent) (orphans, unmerged []*Orphan) {
+	openHeads := make(map[string]bool)
+	for _, pr := range open {
+		openHeads[pr.HeadRefName] = true
+	}
+
+	// PRs are listed newest first, so the first closed PR for a branch wins
+	closedByHead := make(map[string]*PR)
+	for _, pr := range closed {
+		if openHeads[pr.HeadRefName] {
+			continue
+		}
+		if _, exists := closedByHead[pr.HeadRefName]; !exists {
+			closedByHead[pr.HeadRefName] = pr
+		}
+	}
+
+	for _, pr := range open {
+		base := pr.BaseRefName
+		if recorded, ok := parents[pr.HeadRefName]; ok {
+			if parent, exists := closedByHead[recorded.Branch]; exists && parent.State == "MERGED" {
+				base = recorded.Branch
+			}
+		}
+
+		parent, exists := closedByHead[base]
+		if !exists {
+			continue
+		}
+		if parent.State != "MERGED" {
+			unmerged = append(unmerged, &Orphan{PR: pr, Parent: parent})
+			continue
+		}
+
+		newBase := parent.BaseRefName
+		seen := map[string]bool{base: true}
+		next, exists := closedByHead[newBase]
+		for exists && next.State == "MERGED" && !seen[newBase] {
+			seen[newBase] = true
+			newBase = next.BaseRefName
+			next, exists = closedByHead[newBase]
+		}
+
+		switch {
+		case exists && next.State != "MERGED":
+			unmerged = append(unmerged, &Orphan{PR: pr, Parent: next})
+		case exists:
+			// The merged parents form a cycle, leaving no open branch to retarget onto
+			continue
+		default:
+			orphans = append(orphans, &Orphan{PR: pr, Parent: parent, NewBase: newBase})
+		}
+	}
+
+	return orphans, unmerged
+}
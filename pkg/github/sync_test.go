@@ -0,0 +1,146 @@
+package github
+
+import (
+	"testing"
+
+	"github.com/vladimir-ananiev/gh-stack/pkg/git"
+)
+
+func TestFindOrphanedPRs(t *testing.T) {
+	tests := []struct {
+		name     string
+		open     []*PR
+		closed   []*PR
+		expected map[int]string // orphan PR number -> new base
+		parents  map[string]git.StackParent
+		unmerged map[int]int // unmerged orphan PR number -> closed parent
+	}{
+		{
+			name: "no closed PRs",
+			open: []*PR{
+				{Number: 2, HeadRefName: "feature-2", BaseRefName: "feature-1"},
+			},
+			closed:   []*PR{},
+			expected: map[int]string{},
+		},
+		{
+			name: "child of merged PR",
+			open: []*PR{
+				{Number: 2, HeadRefName: "feature-2", BaseRefName: "feature-1"},
+				{Number: 3, HeadRefName: "feature-3", BaseRefName: "feature-2"},
+			},
+			closed: []*PR{
+				{Number: 1, HeadRefName: "feature-1", BaseRefName: "main", State: "MERGED"},
+			},
+			expected: map[int]string{2: "main"},
+		},
+		{
+			name: "chain of merged parents",
+			open: []*PR{
+				{Number: 3, HeadRefName: "feature-3", BaseRefName: "feature-2"},
+			},
+			closed: []*PR{
+				{Number: 2, HeadRefName: "feature-2", BaseRefName: "feature-1", State: "MERGED"},
+				{Number: 1, HeadRefName: "feature-1", BaseRefName: "main", State: "MERGED"},
+			},
+			expected: map[int]string{3: "main"},
+		},
+		{
+			name: "recorded parent merged after the PR was retargeted",
+			open: []*PR{
+				{Number: 2, HeadRefName: "feature-2", BaseRefName: "main"},
+				{Number: 4, HeadRefName: "feature-4", BaseRefName: "feature-2"},
+			},
+			closed: []*PR{
+				{Number: 1, HeadRefName: "feature-1", BaseRefName: "main", State: "MERGED"},
+				{Number: 3, HeadRefName: "feature-3", BaseRefName: "main", State: "CLOSED"},
+			},
+			parents: map[string]git.StackParent{
+				"feature-2": {Branch: "feature-1"},
+				"feature-4": {Branch: "feature-3"},
+			},
+			expected: map[int]string{2: "main"},
+		},
+		{
+			name: "branch reopened as a new PR is not merged",
+			open: []*PR{
+				{Number: 4, HeadRefName: "feature-1", BaseRefName: "main"},
+				{Number: 2, HeadRefName: "feature-2", BaseRefName: "feature-1"},
+			},
+			closed: []*PR{
+				{Number: 1, HeadRefName: "feature-1", BaseRefName: "main", State: "CLOSED"},
+			},
+			expected: map[int]string{},
+		},
+		{
+			name: "child of PR closed without merging",
+			open: []*PR{
+				{Number: 2, HeadRefName: "feature-2", BaseRefName: "feature-1"},
+			},
+			closed: []*PR{
+				{Number: 1, HeadRefName: "feature-1", BaseRefName: "main", State: "CLOSED"},
+			},
+			expected: map[int]string{},
+			unmerged: map[int]int{2: 1},
+		},
+		{
+			name: "merged parent based on PR closed without merging",
+			open: []*PR{
+				{Number: 3, HeadRefName: "feature-3", BaseRefName: "feature-2"},
+			},
+			closed: []*PR{
+				{Number: 2, HeadRefName: "feature-2", BaseRefName: "feature-1", State: "MERGED"},
+				{Number: 1, HeadRefName: "feature-1", BaseRefName: "main", State: "CLOSED"},
+			},
+			expected: map[int]string{},
+			unmerged: map[int]int{3: 1},
+		},
+		{
+			name: "closed parents forming a cycle",
+			open: []*PR{
+				{Number: 3, HeadRefName: "feature-3", BaseRefName: "feature-1"},
+			},
+			closed: []*PR{
+				{Number: 1, HeadRefName: "feature-1", BaseRefName: "feature-2", State: "CLOSED"},
+				{Number: 2, HeadRefName: "feature-2", BaseRefName: "feature-1", State: "CLOSED"},
+			},
+			expected: map[int]string{},
+			unmerged: map[int]int{3: 1},
+		},
+		{
+			name: "merged parents forming a cycle",
+			open: []*PR{
+				{Number: 3, HeadRefName: "feature-3", BaseRefName: "feature-1"},
+			},
+			closed: []*PR{
+				{Number: 1, HeadRefName: "feature-1", BaseRefName: "feature-2", State: "MERGED"},
+				{Number: 2, HeadRefName: "feature-2", BaseRefName: "feature-1", State: "MERGED"},
+			},
+			expected: map[int]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, unmerged := FindOrphanedPRs(tt.open, tt.closed, tt.parents)
+
+			if len(result) != len(tt.expected) {
+				t.Fatalf("FindOrphanedPRs() returned %d orphans, want %d", len(result), len(tt.expected))
+			}
+			for _, orphan := range result {
+				if want, ok := tt.expected[orphan.PR.Number]; !ok || orphan.NewBase != want {
+					t.Errorf("FindOrphanedPRs() #%d new base = %q, want %q", orphan.PR.Number, orphan.NewBase, want)
+				}
+			}
+
+			if len(unmerged) != len(tt.unmerged) {
+				t.Fatalf("FindOrphanedPRs() returned %d unmerged orphans, want %d", len(unmerged), len(tt.unmerged))
+			}
+			for _, orphan := range unmerged {
+				if want, ok := tt.unmerged[orphan.PR.Number]; !ok || orphan.Parent.Number != want {
+					t.Errorf("FindOrphanedPRs() #%d closed parent = #%d, want #%d", orphan.PR.Number, orphan.Parent.Number, want)
+				}
+			}
+		})
+	}
+}